@@ -8,71 +8,90 @@ import (
 // Stmt is an sqlx wrapper around sql.Stmt with extra functionality
 type Stmt struct {
     *sql.Stmt
-    unsafe bool
-    Mapper *reflectx.Mapper
+    // query is the query the statement was prepared from.
+    query     string
+    unsafe    bool
+    errorArgs bool
+    Mapper    *reflectx.Mapper
+}
+
+// SQL returns the query the statement was prepared from.  It is not named
+// Query, which is the method of the embedded sql.Stmt.
+func (s *Stmt) SQL() string {
+    return s.query
 }
 
 // Unsafe returns a version of Stmt which will silently succeed to scan when
 // columns in the SQL result have no fields in the destination struct.
 func (s *Stmt) Unsafe() *Stmt {
-    return &Stmt{Stmt: s.Stmt, unsafe: true, Mapper: s.Mapper}
+    return &Stmt{Stmt: s.Stmt, query: s.query, unsafe: true, errorArgs: s.errorArgs, Mapper: s.Mapper}
+}
+
+// ErrorArgs returns a version of Stmt whose QueryErrors carry the values of
+// the query arguments, like DB.ErrorArgs.
+func (s *Stmt) ErrorArgs() *Stmt {
+    return &Stmt{Stmt: s.Stmt, query: s.query, unsafe: s.unsafe, errorArgs: true, Mapper: s.Mapper}
 }
 
 // Select using the prepared statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) Select(dest interface{}, args ...interface{}) error {
-    return Select(&qStmt{s}, dest, "", args...)
+    return Select(&qStmt{s}, dest, s.query, args...)
 }
 
 // Get using the prepared statement.
 // Any placeholder parameters are replaced with supplied args.
 // An error is returned if the result set is empty.
 func (s *Stmt) Get(dest interface{}, args ...interface{}) error {
-    return Get(&qStmt{s}, dest, "", args...)
+    return Get(&qStmt{s}, dest, s.query, args...)
 }
 
-// MustExec (panic) using this statement.  Note that the query portion of the error
-// output will be blank, as Stmt does not expose its query.
+// MustExec (panic) using this statement.  The error includes the query the
+// statement was prepared from.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) MustExec(args ...interface{}) sql.Result {
-    return MustExec(&qStmt{s}, "", args...)
+    return MustExec(&qStmt{s}, s.query, args...)
 }
 
 // QueryRowx using this statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) QueryRowx(args ...interface{}) *Row {
     qs := &qStmt{s}
-    return qs.QueryRowx("", args...)
+    return qs.QueryRowx(s.query, args...)
 }
 
 // Queryx using this statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) Queryx(args ...interface{}) (*Rows, error) {
     qs := &qStmt{s}
-    return qs.Queryx("", args...)
+    return qs.Queryx(s.query, args...)
 }
 
 // qStmt is an unexposed wrapper which lets you use a Stmt as a Queryer & Execer by
-// implementing those interfaces and ignoring the `query` argument.
+// implementing those interfaces and ignoring the `query` argument.  Errors are
+// returned as QueryErrors for the statement's query.
 type qStmt struct{ *Stmt }
 
 func (q *qStmt) Query(query string, args ...interface{}) (*sql.Rows, error) {
-    return q.Stmt.Query(args...)
+    r, err := q.Stmt.Query(args...)
+    return r, queryError("query", q, q.Stmt.query, args, err)
 }
 
 func (q *qStmt) Queryx(query string, args ...interface{}) (*Rows, error) {
     r, err := q.Stmt.Query(args...)
     if err != nil {
-        return nil, err
+        return nil, queryError("query", q, q.Stmt.query, args, err)
     }
     return &Rows{Rows: r, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}, err
 }
 
 func (q *qStmt) QueryRowx(query string, args ...interface{}) *Row {
     rows, err := q.Stmt.Query(args...)
+    err = queryError("query", q, q.Stmt.query, args, err)
     return &Row{rows: rows, err: err, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}
 }
 
 func (q *qStmt) Exec(query string, args ...interface{}) (sql.Result, error) {
-    return q.Stmt.Exec(args...)
+    r, err := q.Stmt.Exec(args...)
+    return r, queryError("exec", q, q.Stmt.query, args, err)
 }
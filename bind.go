@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/tietang/sqlx/reflectx"
 )
@@ -18,23 +19,42 @@ const (
 	DOLLAR
 	NAMED
 	AT
+	COLON
 )
 
+var defaultBinds = map[int][]string{
+	DOLLAR:   []string{"postgres", "pgx", "pq-timeouts", "cloudsqlpostgres", "ql", "clickhouse"},
+	QUESTION: []string{"mysql", "sqlite3"},
+	NAMED:    []string{"oci8", "ora", "goracle"},
+	AT:       []string{"sqlserver"},
+}
+
+// binds maps driver names to bindtypes;  it is read on every query and only
+// written by BindDriver, so a sync.Map keeps lookups free of lock contention.
+var binds sync.Map
+
+func init() {
+	for bind, drivers := range defaultBinds {
+		for _, driver := range drivers {
+			BindDriver(driver, bind)
+		}
+	}
+}
+
 // BindType returns the bindtype for a given database given a drivername.
 func BindType(driverName string) int {
-	switch driverName {
-	case "postgres", "pgx", "pq-timeouts", "cloudsqlpostgres", "ql":
-		return DOLLAR
-	case "mysql":
-		return QUESTION
-	case "sqlite3":
-		return QUESTION
-	case "oci8", "ora", "goracle":
-		return NAMED
-	case "sqlserver":
-		return AT
-	}
-	return UNKNOWN
+	itype, ok := binds.Load(driverName)
+	if !ok {
+		return UNKNOWN
+	}
+	return itype.(int)
+}
+
+// BindDriver sets the BindType for driverName to bindType.  It can be used to
+// register drivers which wrap one of the known drivers, or to override the
+// bindtype of a known driver.  It is safe to call concurrently with queries.
+func BindDriver(driverName string, bindType int) {
+	binds.Store(driverName, bindType)
 }
 
 // FIXME: this should be able to be tolerant of escaped ?'s in queries without
@@ -62,6 +82,8 @@ func Rebind(bindType int, query string) string {
 			rqb = append(rqb, ':', 'a', 'r', 'g')
 		case AT:
 			rqb = append(rqb, '@', 'p')
+		case COLON:
+			rqb = append(rqb, ':')
 		}
 
 		j++
@@ -98,16 +120,41 @@ func rebindBuff(bindType int, query string) string {
 	return rqb.String()
 }
 
+// InOptions control how In expands slice arguments.
+type InOptions struct {
+	// AllowEmpty renders an empty slice as `NULL` (or a tuple of NULLs) rather
+	// than returning an error, so that `x IN (?)` matches no rows.
+	AllowEmpty bool
+}
+
 // In expands slice values in args, returning the modified query string
 // and a new arg list that can be executed by a database. The `query` should
 // use the `?` bindVar.  The return value uses the `?` bindVar.
+//
+// Slices of slices and slices of structs are expanded into a list of tuples,
+// so that `(a, b) IN (?)` becomes `(a, b) IN ((?, ?), (?, ?))`.  Struct fields
+// are used in declaration order, following embedded structs.
 func In(query string, args ...interface{}) (string, []interface{}, error) {
+	return InWithOptions(nil, query, args...)
+}
+
+// InWithOptions is like In, but expands slices according to options.
+func InWithOptions(options *InOptions, query string, args ...interface{}) (string, []interface{}, error) {
 	// argMeta stores reflect.Value and length for slices and
 	// the value itself for non-slice arguments
 	type argMeta struct {
 		v      reflect.Value
 		i      interface{}
 		length int
+		slice  bool
+		// for lists of tuples, the width of each tuple and, for structs,
+		// the traversals of the fields making up the tuple
+		width  int
+		fields [][]int
+	}
+
+	if options == nil {
+		options = &InOptions{}
 	}
 
 	var flatArgsCount int
@@ -123,16 +170,28 @@ func In(query string, args ...interface{}) (string, []interface{}, error) {
 		t := reflectx.Deref(v.Type())
 
 		// []byte is a driver.Value type so it should not be expanded
-		if t.Kind() == reflect.Slice && t != reflect.TypeOf([]byte{}) {
+		if t.Kind() == reflect.Slice && !isBytes(t) {
+			v = reflect.Indirect(v)
 			meta[i].length = v.Len()
 			meta[i].v = v
+			meta[i].slice = true
 
 			anySlices = true
-			flatArgsCount += meta[i].length
 
-			if meta[i].length == 0 {
+			if meta[i].length == 0 && !options.AllowEmpty {
 				return "", nil, errors.New("empty slice passed to 'in' query")
 			}
+
+			width, fields, err := tupleWidth(v)
+			if err != nil {
+				return "", nil, err
+			}
+			meta[i].width, meta[i].fields = width, fields
+			if width > 0 {
+				flatArgsCount += meta[i].length * width
+			} else {
+				flatArgsCount += meta[i].length
+			}
 		} else {
 			meta[i].i = arg
 			flatArgsCount++
@@ -165,21 +224,34 @@ func In(query string, args ...interface{}) (string, []interface{}, error) {
 		// not a slice, continue.
 		// our questionmark will either be written before the next expansion
 		// of a slice or after the loop when writing the rest of the query
-		if argMeta.length == 0 {
+		if !argMeta.slice {
 			offset = offset + i + 1
 			newArgs = append(newArgs, argMeta.i)
 			continue
 		}
 
-		// write everything up to and including our ? character
-		buf = append(buf, query[:offset+i+1]...)
-
-		for si := 1; si < argMeta.length; si++ {
-			buf = append(buf, ", ?"...)
+		// write everything up to but not including our ? character
+		buf = append(buf, query[:offset+i]...)
+
+		switch {
+		case argMeta.length == 0:
+			buf = appendTuple(buf, "NULL", argMeta.width)
+		case argMeta.width > 0:
+			for si := 0; si < argMeta.length; si++ {
+				if si > 0 {
+					buf = append(buf, ", "...)
+				}
+				buf = appendTuple(buf, "?", argMeta.width)
+				newArgs = appendTupleArgs(newArgs, argMeta.v.Index(si), argMeta.fields)
+			}
+		default:
+			buf = append(buf, '?')
+			for si := 1; si < argMeta.length; si++ {
+				buf = append(buf, ", ?"...)
+			}
+			newArgs = appendReflectSlice(newArgs, argMeta.v, argMeta.length)
 		}
 
-		newArgs = appendReflectSlice(newArgs, argMeta.v, argMeta.length)
-
 		// slice the query and reset the offset. this avoids some bookkeeping for
 		// the write after the loop
 		query = query[offset+i+1:]
@@ -195,6 +267,105 @@ func In(query string, args ...interface{}) (string, []interface{}, error) {
 	return string(buf), newArgs, nil
 }
 
+// tupleWidth returns the number of values in each element of a slice which
+// In should expand into a list of tuples, or 0 if the elements are scalars.
+// For slices of structs, the traversals of the tuple's fields are returned.
+func tupleWidth(v reflect.Value) (int, [][]int, error) {
+	et := v.Type().Elem()
+	if et.Implements(_valuerInterface) {
+		return 0, nil, nil
+	}
+	switch base := reflectx.Deref(et); base.Kind() {
+	case reflect.Struct:
+		if isScannable(base) || reflect.PtrTo(base).Implements(_valuerInterface) {
+			return 0, nil, nil
+		}
+		fields := tupleFields(base)
+		return len(fields), fields, nil
+	case reflect.Slice, reflect.Array:
+		if isBytes(base) {
+			return 0, nil, nil
+		}
+		if v.Len() == 0 {
+			// the width of the tuples is unknown, so there is no valid way
+			// to write an empty list
+			return 0, nil, errors.New("empty list of tuples passed to 'in' query")
+		}
+		width := reflect.Indirect(v.Index(0)).Len()
+		for i := 1; i < v.Len(); i++ {
+			if reflect.Indirect(v.Index(i)).Len() != width {
+				return 0, nil, errors.New("tuples passed to 'in' query have different lengths")
+			}
+		}
+		if width == 0 {
+			return 0, nil, errors.New("empty tuple passed to 'in' query")
+		}
+		return width, nil, nil
+	}
+	return 0, nil, nil
+}
+
+// isBytes reports whether t is a byte slice, including named types like
+// json.RawMessage, which are bound as a single value.
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// tupleFields returns the traversals of the fields of a struct which make up
+// a tuple, in declaration order.  Embedded structs are flattened.
+func tupleFields(t reflect.Type) [][]int {
+	var fields [][]int
+	var walk func(fi *reflectx.FieldInfo)
+	walk = func(fi *reflectx.FieldInfo) {
+		for _, child := range fi.Children {
+			if child == nil {
+				continue
+			}
+			if child.Embedded {
+				walk(child)
+				continue
+			}
+			fields = append(fields, child.Index)
+		}
+	}
+	walk(mapper().TypeMap(t).Tree)
+	return fields
+}
+
+// appendTuple appends width copies of s, separated by commas and enclosed in
+// parens, to buf.  If width is 0, a single unenclosed s is appended.
+func appendTuple(buf []byte, s string, width int) []byte {
+	if width == 0 {
+		return append(buf, s...)
+	}
+	buf = append(buf, '(')
+	for i := 0; i < width; i++ {
+		if i > 0 {
+			buf = append(buf, ", "...)
+		}
+		buf = append(buf, s...)
+	}
+	return append(buf, ')')
+}
+
+// appendTupleArgs appends the values of a single tuple to args.  If fields is
+// nil, the tuple is a slice or array, otherwise it is a struct.
+func appendTupleArgs(args []interface{}, v reflect.Value, fields [][]int) []interface{} {
+	v = reflect.Indirect(v)
+	if fields == nil {
+		return appendReflectSlice(args, v, v.Len())
+	}
+	for _, traversal := range fields {
+		f := reflectx.ValidFieldByIndexes(v, traversal)
+		if !f.IsValid() {
+			args = append(args, nil)
+			continue
+		}
+		args = append(args, f.Interface())
+	}
+	return args
+}
+
 func appendReflectSlice(args []interface{}, v reflect.Value, vlen int) []interface{} {
 	switch val := v.Interface().(type) {
 	case []interface{}:
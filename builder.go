@@ -1,19 +1,118 @@
 package sqlx
 
 import (
+    "context"
+    "database/sql/driver"
     "fmt"
     "github.com/kataras/go-errors"
     "github.com/tietang/sqlx/reflectx"
     "reflect"
     "regexp"
     "sort"
+    "strings"
+    "time"
     "upper.io/db.v3"
 )
 
+// MapOp is the kind of write an item is mapped for.
+type MapOp int
+
+const (
+    // MapAny maps an item without calling any hooks.
+    MapAny MapOp = iota
+    // MapInsert calls the item's BeforeInsert and Validate hooks.
+    MapInsert
+    // MapUpdate calls the item's BeforeUpdate and Validate hooks.
+    MapUpdate
+)
+
 // MapOptions represents options for the mapper.
 type MapOptions struct {
     IncludeZeroed bool
     IncludeNil    bool
+    Op            MapOp
+    // Clock overrides the package Clock for the autocreate and autoupdate
+    // columns, which are set to its time in UTC truncated to TimePrecision.
+    Clock         func() time.Time
+    TimePrecision time.Duration
+    // IncludeReadOnly maps readonly and scanonly columns, IncludeInsertOnly
+    // maps insertonly columns on update, and IncludeDefault maps default
+    // columns even when they are zero.
+    IncludeReadOnly   bool
+    IncludeInsertOnly bool
+    IncludeDefault    bool
+    // ZeroAsDefault maps zero omitempty fields included by IncludeZeroed to
+    // the DEFAULT keyword instead of their zero value.  Keywords can not be
+    // bound as arguments;  queries built from the mapped values must render
+    // them with Placeholders.
+    ZeroAsDefault bool
+    // Strict makes mapping a zero omitempty field as its zero value an error,
+    // since the zero may stand for either an empty or an unset field.
+    Strict bool
+    // Order is the order of the mapped columns.
+    Order MapOrder
+    // Columns restricts the mapping to the listed columns, which are also the
+    // order of OrderColumns.  Exclude leaves out the listed columns.
+    Columns []string
+    Exclude []string
+    // Mapper maps struct fields to columns, the package mapper if it is nil.
+    // The insert and update helpers use the Mapper of their handle.
+    Mapper *reflectx.Mapper
+}
+
+// mapper returns the mapper of the options.
+func (o *MapOptions) mapper() *reflectx.Mapper {
+    if o.Mapper != nil {
+        return o.Mapper
+    }
+    return mapper()
+}
+
+// MapOrder is the order Map returns columns in.
+type MapOrder int
+
+const (
+    // OrderAlphabetical sorts the columns by name.
+    OrderAlphabetical MapOrder = iota
+    // OrderStruct keeps the order the fields of a struct are declared in,
+    // with the fields of nested structs after those of their parent.  The
+    // keys of maps are sorted by name.
+    OrderStruct
+    // OrderColumns orders the columns as in MapOptions.Columns.
+    OrderColumns
+)
+
+// Keyword is an SQL keyword which the insert and update helpers write in
+// place of a bindvar, eg. Default.
+type Keyword string
+
+// Default is mapped for columns which should be set to their default value,
+// see MapOptions.ZeroAsDefault.
+const Default Keyword = "DEFAULT"
+
+// Value makes binding a keyword as a query argument an error, instead of
+// writing the keyword as a string.
+func (k Keyword) Value() (driver.Value, error) {
+    return nil, fmt.Errorf("sqlx: keyword %s can not be bound as an argument, see Placeholders", string(k))
+}
+
+// Placeholders returns the placeholders and arguments for values returned by
+// Map: a ? bindvar for every value, or the keyword itself for Keyword values,
+// which are not bound.  Queries using them must be rebound for drivers which
+// do not use ?.  sqlite has no DEFAULT values;  leave those columns out
+// instead, as InsertTable does.
+func Placeholders(values []interface{}) ([]string, []interface{}) {
+    placeholders := make([]string, len(values))
+    args := make([]interface{}, 0, len(values))
+    for i, v := range values {
+        if k, ok := v.(Keyword); ok {
+            placeholders[i] = string(k)
+            continue
+        }
+        placeholders[i] = "?"
+        args = append(args, v)
+    }
+    return placeholders, args
 }
 
 var defaultMapOptions = MapOptions{
@@ -51,6 +150,13 @@ func snakeCasedName(name string) string {
 
 // Map receives a pointer to map or struct and maps it to columns and values.
 func Map(item interface{}, options *MapOptions) (string, []string, []interface{}, error) {
+    return MapContext(context.Background(), item, options)
+}
+
+// MapContext is like Map, and passes ctx to the hooks of the item.  A struct
+// passed by value is copied before the hooks are called, so their changes are
+// mapped but not seen by the caller.
+func MapContext(ctx context.Context, item interface{}, options *MapOptions) (string, []string, []interface{}, error) {
     var fv fieldValue
     if options == nil {
         options = &defaultMapOptions
@@ -63,88 +169,138 @@ func Map(item interface{}, options *MapOptions) (string, []string, []interface{}
 
     itemT := itemV.Type()
 
+    if options.Op != MapAny && reflectx.Deref(itemT).Kind() == reflect.Struct {
+        if itemT.Kind() != reflect.Ptr {
+            ptr := reflect.New(itemT)
+            ptr.Elem().Set(itemV)
+            itemV, itemT, item = ptr, ptr.Type(), ptr.Interface()
+        }
+        touchTimestamps(itemV.Elem(), options.mapper().TypeMap(itemT).Index, options)
+        if err := beforeWrite(ctx, item, options.Op); err != nil {
+            return "", nil, nil, err
+        }
+    }
+
     if itemT.Kind() == reflect.Ptr {
         // Single dereference. Just in case the user passes a pointer to struct
         // instead of a struct.
-        item = itemV.Elem().Interface()
-        itemV = reflect.ValueOf(item)
+        itemV = itemV.Elem()
+        item = itemV.Interface()
         itemT = itemV.Type()
     }
     name := snakeCasedName(itemV.Type().Name())
 
+    include, exclude := columnSet(options.Columns), columnSet(options.Exclude)
+    mapped := func(column string) bool {
+        return (include == nil || include[column]) && !exclude[column]
+    }
+    switch options.Order {
+    case OrderStruct:
+        fv.rank = map[string]int{}
+    case OrderColumns:
+        fv.rank = map[string]int{}
+        for i, column := range options.Columns {
+            fv.rank[column] = i
+        }
+    }
+
     switch itemT.Kind() {
     case reflect.Struct:
-        fieldMap := mapper().TypeMap(itemT).Names
+        fieldMap, err := structFields(options.mapper().TypeMap(itemT))
+        if err != nil {
+            return "", nil, nil, fmt.Errorf("sqlx: %v in %s", err, itemT)
+        }
+        if options.Op != MapAny {
+            if err = writableColumns(fieldMap, itemT); err != nil {
+                return "", nil, nil, err
+            }
+        }
         nfields := len(fieldMap)
 
         fv.values = make([]interface{}, 0, nfields)
         fv.fields = make([]string, 0, nfields)
 
         for _, fi := range fieldMap {
+            column := fi.Column()
+            if !mapped(column) {
+                continue
+            }
+            if options.Order == OrderStruct {
+                fv.rank[column] = len(fv.rank)
+            }
 
             // Field options
             _, tagOmitEmpty := fi.Options["omitempty"]
+            if _, version := fi.Options["version"]; version {
+                // a zero version is written too, or updates would not check it
+                tagOmitEmpty = false
+            }
+            if _, autoCreate := fi.Options["autocreate"]; autoCreate && options.Op == MapUpdate {
+                continue
+            }
+            if !writable(fi, options) {
+                continue
+            }
 
             fld := reflectx.FieldByIndexesReadOnly(itemV, fi.Index)
+            isZero := isZeroValue(fld, fi)
+            if isZero && defaulted(fi, options) {
+                continue
+            }
+
             if fld.Kind() == reflect.Ptr && fld.IsNil() {
                 if tagOmitEmpty && !options.IncludeNil {
                     continue
                 }
-                fv.fields = append(fv.fields, fi.Name)
-                if tagOmitEmpty {
-                    fv.values = append(fv.values, "")
-                } else {
-                    fv.values = append(fv.values, nil)
-                }
+                fv.fields = append(fv.fields, column)
+                fv.values = append(fv.values, nil)
                 continue
             }
 
             value := fld.Interface()
 
-            isZero := false
-            if t, ok := fld.Interface().(hasIsZero); ok {
-                if t.IsZero() {
-                    isZero = true
-                }
-            } else if fld.Kind() == reflect.Array || fld.Kind() == reflect.Slice {
-                if fld.Len() == 0 {
-                    isZero = true
+            if isZero && tagOmitEmpty {
+                _, key := fi.Options["key"]
+                switch {
+                case !options.IncludeZeroed:
+                    continue
+                case options.ZeroAsDefault && !key:
+                    fv.fields = append(fv.fields, column)
+                    fv.values = append(fv.values, Default)
+                    continue
+                case options.Strict:
+                    return "", nil, nil, fmt.Errorf("sqlx: omitempty field %s of %s is zero, set ZeroAsDefault to write DEFAULT", fi.Field.Name, itemT)
                 }
-            } else if reflect.DeepEqual(fi.Zero.Interface(), value) {
-                isZero = true
             }
 
-            if isZero && tagOmitEmpty && !options.IncludeZeroed {
-                continue
-            }
-
-            fv.fields = append(fv.fields, fi.Name)
+            fv.fields = append(fv.fields, column)
             v, err := marshal(value)
             if err != nil {
                 return "", nil, nil, err
             }
-            if isZero && tagOmitEmpty {
-                v = ""
-            }
             fv.values = append(fv.values, v)
         }
 
     case reflect.Map:
         nfields := itemV.Len()
-        fv.values = make([]interface{}, nfields)
-        fv.fields = make([]string, nfields)
+        fv.values = make([]interface{}, 0, nfields)
+        fv.fields = make([]string, 0, nfields)
         mkeys := itemV.MapKeys()
 
-        for i, keyV := range mkeys {
+        for _, keyV := range mkeys {
+            field := fmt.Sprintf("%v", keyV.Interface())
+            if !mapped(field) {
+                continue
+            }
             valv := itemV.MapIndex(keyV)
-            fv.fields[i] = fmt.Sprintf("%v", keyV.Interface())
 
             v, err := marshal(valv.Interface())
             if err != nil {
                 return "", nil, nil, err
             }
 
-            fv.values[i] = v
+            fv.fields = append(fv.fields, field)
+            fv.values = append(fv.values, v)
         }
     default:
         return "", nil, nil, ErrExpectingPointerToEitherMapOrStruct
@@ -165,9 +321,88 @@ func marshal(v interface{}) (interface{}, error) {
     return v, nil
 }
 
+// structFields returns the fields of a struct written as columns, in the order
+// of the struct.  Embedded and nested structs are flattened into the columns
+// of their fields, as when scanning, unless they are column values themselves,
+// eg. time.Time.  Two fields with the same column are an error.
+func structFields(tm *reflectx.StructMap) ([]*reflectx.FieldInfo, error) {
+    fields := make([]*reflectx.FieldInfo, 0, len(tm.Names))
+    columns := make(map[string]*reflectx.FieldInfo, len(tm.Names))
+    values := map[*reflectx.FieldInfo]bool{}
+    for _, fi := range tm.Index {
+        if fi.Name == "" || fi.Embedded || inValue(fi, values) {
+            continue
+        }
+        if !isColumnType(fi.Field.Type) {
+            continue
+        }
+        // the children of SelectGrouped are rows of their own
+        if _, many := fi.Options["many"]; many {
+            continue
+        }
+        values[fi] = true
+        if other, ok := columns[fi.Column()]; ok {
+            return nil, fmt.Errorf("ambiguous column %s of fields %s and %s", fi.Column(), other.Path, fi.Path)
+        }
+        columns[fi.Column()] = fi
+        fields = append(fields, fi)
+    }
+    return fields, nil
+}
+
+// writableColumns returns an error if a field of a nested struct maps to its
+// path, eg. address.city, which is not a valid column in an INSERT or UPDATE.
+// Such structs must be embedded, or given a prefix option.
+func writableColumns(fields []*reflectx.FieldInfo, t reflect.Type) error {
+    for _, fi := range fields {
+        if strings.Contains(fi.Column(), ".") {
+            return fmt.Errorf("sqlx: field %s of %s maps to the column %s, which can not be written; embed its struct or give it a prefix option", fi.Path, t, fi.Column())
+        }
+    }
+    return nil
+}
+
+// inValue reports whether fi is nested in one of the struct fields written as
+// values.
+func inValue(fi *reflectx.FieldInfo, values map[*reflectx.FieldInfo]bool) bool {
+    for p := fi.Parent; p != nil; p = p.Parent {
+        if values[p] {
+            return true
+        }
+    }
+    return false
+}
+
+// isColumnType reports whether fields of type t are written as a single column
+// value rather than as the columns of their fields.
+func isColumnType(t reflect.Type) bool {
+    t = reflectx.Deref(t)
+    if t.Kind() != reflect.Struct || isScannable(t) {
+        return true
+    }
+    pt := reflect.PtrTo(t)
+    return pt.Implements(_valuerInterface) || pt.Implements(_marshalerInterface)
+}
+
+var _marshalerInterface = reflect.TypeOf((*db.Marshaler)(nil)).Elem()
+
+// columnSet returns the set of columns, or nil if there are none.
+func columnSet(columns []string) map[string]bool {
+    if len(columns) == 0 {
+        return nil
+    }
+    set := make(map[string]bool, len(columns))
+    for _, column := range columns {
+        set[column] = true
+    }
+    return set
+}
+
 type fieldValue struct {
     fields []string
     values []interface{}
+    // rank orders the fields ranked first, the rest are sorted by name
+    rank map[string]int
 }
 
 func (fv *fieldValue) Len() int {
@@ -180,5 +415,13 @@ func (fv *fieldValue) Swap(i, j int) {
 }
 
 func (fv *fieldValue) Less(i, j int) bool {
+    ri, iok := fv.rank[fv.fields[i]]
+    rj, jok := fv.rank[fv.fields[j]]
+    switch {
+    case iok != jok:
+        return iok
+    case iok && ri != rj:
+        return ri < rj
+    }
     return fv.fields[i] < fv.fields[j]
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Options are the options of Generate.
+type Options struct {
+	// Package is the package name of the generated file.
+	Package string
+	// TableName generates a TableName method for each struct.
+	TableName bool
+	// CRUD generates insert, get, update and delete functions for each
+	// struct;  only the insert function for tables without a primary key.
+	CRUD bool
+}
+
+// structDef is a struct generated for a table.
+type structDef struct {
+	Name   string
+	Table  string
+	Fields []fieldDef
+	Keys   []fieldDef
+	// Where is the condition on the primary key of the table.
+	Where string
+	// Auto is the primary key if it is a single integer column generated by
+	// the database, which the insert function reads back.
+	Auto *fieldDef
+}
+
+// fieldDef is a field generated for a column.
+type fieldDef struct {
+	Name   string
+	Type   string
+	Tag    string
+	Column string
+	// Param is the name of the field as a function parameter.
+	Param string
+}
+
+// Generate writes the Go source of the structs for tables to w.
+func Generate(w io.Writer, tables []Table, options Options) error {
+	imports := map[string]bool{}
+	structs := make([]structDef, 0, len(tables))
+	for _, t := range tables {
+		s, err := newStructDef(t, imports)
+		if err != nil {
+			return err
+		}
+		structs = append(structs, s)
+	}
+	if options.CRUD && len(structs) > 0 {
+		imports["database/sql"] = true
+		imports["github.com/tietang/sqlx"] = true
+	}
+	var paths []string
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, struct {
+		Options
+		Imports []string
+		Structs []structDef
+	}{options, paths, structs})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// newStructDef returns the struct for the table t, adding the packages of its
+// field types to imports.
+func newStructDef(t Table, imports map[string]bool) (structDef, error) {
+	s := structDef{Name: exportedName(t.Name), Table: t.Name}
+	if len(t.Columns) == 0 {
+		return s, fmt.Errorf("table %s has no columns", t.Name)
+	}
+
+	names := map[string]int{}
+	var where []string
+	for _, c := range t.Columns {
+		typ, pkg := goType(c)
+		if pkg != "" {
+			imports[pkg] = true
+		}
+		f := fieldDef{Name: exportedName(c.Name), Type: typ, Tag: c.Name, Column: c.Name}
+		// columns like user_id and userId have the same field name
+		if n := names[f.Name]; n > 0 {
+			names[f.Name]++
+			f.Name = fmt.Sprintf("%s%d", f.Name, n+1)
+		} else {
+			names[f.Name] = 1
+		}
+		if c.Key {
+			f.Tag += ",key"
+			f.Param = paramName(c.Name)
+		}
+		// zero values of generated columns are left to the database
+		if c.AutoIncrement {
+			f.Tag += ",default"
+		}
+		if c.Key {
+			s.Keys = append(s.Keys, f)
+			where = append(where, c.Name+" = ?")
+		}
+		s.Fields = append(s.Fields, f)
+	}
+	s.Where = strings.Join(where, " AND ")
+	if len(s.Keys) == 1 && strings.HasSuffix(s.Keys[0].Tag, ",default") && isInteger(s.Keys[0].Type) {
+		s.Auto = &s.Keys[0]
+	}
+	return s, nil
+}
+
+// isInteger reports whether typ is a Go integer type.
+func isInteger(typ string) bool {
+	return strings.HasPrefix(typ, "int") || strings.HasPrefix(typ, "uint")
+}
+
+// goType returns the Go type for the column c, and the package it needs.
+func goType(c Column) (typ, pkg string) {
+	t := strings.ToLower(strings.TrimSpace(c.Type))
+	if i := strings.IndexByte(t, '('); i >= 0 {
+		if t[i:] == "(1)" && strings.HasPrefix(t, "tinyint") {
+			// mysql booleans
+			t = "boolean"
+		} else if strings.HasPrefix(t, "bit") && t[i:] == "(1)" {
+			return "types.BitBool", "github.com/tietang/sqlx/types"
+		} else {
+			t = t[:i] + t[strings.IndexByte(t, ')')+1:]
+		}
+	}
+	t = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(t), "unsigned"))
+
+	switch {
+	case t == "json" || t == "jsonb":
+		if c.Nullable {
+			return "types.NullJSONText", "github.com/tietang/sqlx/types"
+		}
+		return "types.JSONText", "github.com/tietang/sqlx/types"
+	case t == "bool" || t == "boolean":
+		return nullable(c, "bool", "sql.NullBool")
+	case integerType.MatchString(t):
+		return nullable(c, "int64", "sql.NullInt64")
+	case strings.Contains(t, "char") || strings.Contains(t, "text") || strings.Contains(t, "clob") ||
+		t == "uuid" || t == "enum" || t == "set":
+		return nullable(c, "string", "sql.NullString")
+	case strings.Contains(t, "real") || strings.Contains(t, "floa") || strings.Contains(t, "doub") ||
+		t == "numeric" || t == "decimal":
+		return nullable(c, "float64", "sql.NullFloat64")
+	case strings.Contains(t, "blob") || strings.Contains(t, "binary") || t == "bytea":
+		return "[]byte", ""
+	case strings.HasPrefix(t, "date") || strings.HasPrefix(t, "time"):
+		if c.Nullable {
+			return "sql.NullTime", "database/sql"
+		}
+		return "time.Time", "time"
+	}
+	return "interface{}", ""
+}
+
+var integerType = regexp.MustCompile(`^(tiny|small|medium|big)?(int|integer|int[248]|serial[248]?)$`)
+
+func nullable(c Column, typ, nullType string) (string, string) {
+	if c.Nullable {
+		return nullType, "database/sql"
+	}
+	return typ, ""
+}
+
+// initialisms are the words written in upper case in Go names.
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "UID": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+var nameSeparators = regexp.MustCompile(`[^\pL\pN]+`)
+
+// words splits a name like "user_id" or "userId" into its words.
+func words(name string) []string {
+	var words []string
+	for _, part := range nameSeparators.Split(name, -1) {
+		start := 0
+		runes := []rune(part)
+		for i := 1; i < len(runes); i++ {
+			if unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1]) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			words = append(words, string(runes[start:]))
+		}
+	}
+	return words
+}
+
+// exportedName returns the exported Go name for a table or column name.
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, w := range words(name) {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(strings.ToLower(w))
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "X" + s
+	}
+	return s
+}
+
+// paramName returns the name of a function parameter for a column name.
+func paramName(name string) string {
+	ws := words(name)
+	if len(ws) == 0 {
+		return "key"
+	}
+	s := strings.ToLower(ws[0])
+	if len(ws) > 1 {
+		s += exportedName(strings.Join(ws[1:], "_"))
+	}
+	if !unicode.IsLetter([]rune(s)[0]) {
+		s = "x" + s
+	}
+	// db and row are the other names in the generated functions
+	if token.Lookup(s).IsKeyword() || s == "db" || s == "row" || s == "err" {
+		s += "Key"
+	}
+	return s
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by sqlxgen. DO NOT EDIT.
+
+package {{.Package}}
+{{if .Imports}}
+import (
+{{range .Imports}}	"{{.}}"
+{{end}})
+{{end}}
+{{range $s := .Structs}}
+// {{.Name}} is a row of the {{.Table}} table.
+type {{.Name}} struct {
+{{range .Fields}}	{{.Name}} {{.Type}} ` + "`" + `db:"{{.Tag}}"` + "`" + `
+{{end}}}
+{{if $.TableName}}
+// TableName returns the name of the table of {{.Name}}.
+func ({{.Name}}) TableName() string {
+	return {{printf "%q" .Table}}
+}
+{{end}}{{if $.CRUD}}
+// Insert{{.Name}} inserts row into the {{.Table}} table.{{if .Auto}}  If row.{{.Auto.Name}} is
+// zero, the database generates it, and it is set on row if the driver reports
+// it as the LastInsertId.{{end}}
+func Insert{{.Name}}(db *sqlx.DB, row *{{.Name}}) (sql.Result, error) {
+{{- if .Auto}}
+	generated := row.{{.Auto.Name}} == 0
+	res, err := db.InsertTable({{printf "%q" .Table}}, row)
+	if err != nil || !generated {
+		return res, err
+	}
+	// drivers without LastInsertId, such as lib/pq, leave the key zero
+	if id, err := res.LastInsertId(); err == nil {
+		row.{{.Auto.Name}} = {{.Auto.Type}}(id)
+	}
+	return res, nil
+{{- else}}
+	return db.InsertTable({{printf "%q" .Table}}, row)
+{{- end}}
+}
+{{if .Keys}}
+// Get{{.Name}} returns the row of the {{.Table}} table with the given key.
+func Get{{.Name}}(db *sqlx.DB{{range .Keys}}, {{.Param}} {{.Type}}{{end}}) (*{{.Name}}, error) {
+	var row {{.Name}}
+	query := db.Rebind({{printf "%q" (printf "SELECT * FROM %s WHERE %s" .Table .Where)}})
+	if err := db.Get(&row, query{{range .Keys}}, {{.Param}}{{end}}); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// Update{{.Name}} updates the row of the {{.Table}} table with the key of row.
+func Update{{.Name}}(db *sqlx.DB, row *{{.Name}}) (sql.Result, error) {
+	return db.UpdateTable({{printf "%q" .Table}}, row)
+}
+
+// Delete{{.Name}} deletes the row of the {{.Table}} table with the key of row.
+func Delete{{.Name}}(db *sqlx.DB, row *{{.Name}}) (sql.Result, error) {
+	return db.DeleteTable({{printf "%q" .Table}}, row)
+}
+{{end}}{{end}}{{end}}`))
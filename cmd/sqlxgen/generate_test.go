@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tietang/sqlx"
+)
+
+func TestGoType(t *testing.T) {
+	tests := []struct {
+		typ      string
+		nullable bool
+		want     string
+	}{
+		{"INTEGER", false, "int64"},
+		{"bigint(20) unsigned", true, "sql.NullInt64"},
+		{"serial", false, "int64"},
+		{"interval", false, "interface{}"},
+		{"tinyint(1)", false, "bool"},
+		{"boolean", true, "sql.NullBool"},
+		{"character varying", true, "sql.NullString"},
+		{"varchar(255)", false, "string"},
+		{"double precision", false, "float64"},
+		{"decimal(10,2)", true, "sql.NullFloat64"},
+		{"timestamp with time zone", false, "time.Time"},
+		{"datetime", true, "sql.NullTime"},
+		{"jsonb", false, "types.JSONText"},
+		{"json", true, "types.NullJSONText"},
+		{"bytea", true, "[]byte"},
+		{"bit(1)", false, "types.BitBool"},
+		{"", true, "interface{}"},
+	}
+	for _, test := range tests {
+		got, _ := goType(Column{Type: test.typ, Nullable: test.nullable})
+		if got != test.want {
+			t.Errorf("expected %s for %q, got %s", test.want, test.typ, got)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	names := map[string]string{
+		"user_id":      "UserID",
+		"userId":       "UserID",
+		"api_key":      "APIKey",
+		"order-items":  "OrderItems",
+		"2fa":          "X2fa",
+		"person":       "Person",
+		"html_url_xml": "HTMLURLXML",
+	}
+	for name, want := range names {
+		if got := exportedName(name); got != want {
+			t.Errorf("expected %s for %q, got %s", want, name, got)
+		}
+	}
+	params := map[string]string{
+		"user_id": "userID",
+		"id":      "id",
+		"type":    "typeKey",
+		"row":     "rowKey",
+	}
+	for name, want := range params {
+		if got := paramName(name); got != want {
+			t.Errorf("expected parameter %s for %q, got %s", want, name, got)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.MustExec(`
+CREATE TABLE person (id integer PRIMARY KEY, first_name text NOT NULL, email varchar(255), added_at timestamp);
+CREATE TABLE tag_link (tag_id integer, post_id integer, note json, PRIMARY KEY (tag_id, post_id));
+CREATE TABLE log (message text NOT NULL);`)
+
+	tables, err := loadTables(db, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 3 || tables[0].Name != "log" || tables[1].Name != "person" || tables[2].Name != "tag_link" {
+		t.Fatalf("unexpected tables %#v", tables)
+	}
+	person := tables[1]
+	if c := person.Columns[0]; c.Name != "id" || !c.Key || c.Nullable || !c.AutoIncrement {
+		t.Errorf("expected a generated primary key id column, got %#v", c)
+	}
+	if c := tables[2].Columns[0]; c.AutoIncrement {
+		t.Errorf("expected a composite key not to be generated, got %#v", c)
+	}
+	if c := person.Columns[2]; c.Name != "email" || !c.Nullable {
+		t.Errorf("expected a nullable email column, got %#v", c)
+	}
+	if _, err = loadTables(db, "", []string{"missing"}); err == nil {
+		t.Error("expected an error for a missing table")
+	}
+
+	var buf bytes.Buffer
+	if err = Generate(&buf, tables, Options{Package: "models", TableName: true, CRUD: true}); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	if _, err = parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"package models",
+		`"github.com/tietang/sqlx/types"`,
+		"ID        int64          `db:\"id,key,default\"`",
+		"TagID  int64              `db:\"tag_id,key\"`",
+		"Email     sql.NullString `db:\"email\"`",
+		"AddedAt   sql.NullTime   `db:\"added_at\"`",
+		"Note   types.NullJSONText `db:\"note\"`",
+		`func (Person) TableName() string`,
+		`func GetTagLink(db *sqlx.DB, tagID int64, postID int64) (*TagLink, error)`,
+		`"SELECT * FROM tag_link WHERE tag_id = ? AND post_id = ?"`,
+		`func InsertLog(db *sqlx.DB, row *Log) (sql.Result, error)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("expected the generated code to contain %s, got\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "func GetLog") {
+		t.Error("expected no get function for a table without a primary key")
+	}
+}
+
+// crudMain runs the generated functions for the person table of TestGenerate.
+const crudMain = `package main
+
+import (
+	"fmt"
+	"log"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tietang/sqlx"
+)
+
+func main() {
+	db := sqlx.MustConnect("sqlite3", ":memory:")
+	db.MustExec("CREATE TABLE person (id integer PRIMARY KEY, first_name text NOT NULL, email varchar(255))")
+	for _, name := range []string{"ann", "bob"} {
+		p := Person{FirstName: name}
+		if _, err := InsertPerson(db, &p); err != nil {
+			log.Fatal(err)
+		}
+		p.FirstName += "!"
+		if _, err := UpdatePerson(db, &p); err != nil {
+			log.Fatal(err)
+		}
+		got, err := GetPerson(db, p.ID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(got.ID, got.FirstName)
+	}
+	if _, err := DeletePerson(db, &Person{ID: 1}); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := GetPerson(db, 1); err == nil {
+		log.Fatal("expected the deleted person to be gone")
+	}
+}
+`
+
+// TestGenerateCRUD compiles the generated functions and runs them against
+// sqlite3, inserting twice into a table with a generated key.
+func TestGenerateCRUD(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles a program")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool")
+	}
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.MustExec(`CREATE TABLE person (id integer PRIMARY KEY, first_name text NOT NULL, email varchar(255))`)
+	tables, err := loadTables(db, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the program must be in the module to import sqlx;  the underscore
+	// keeps the directory out of ./... patterns
+	dir, err := ioutil.TempDir(".", "_crud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buf bytes.Buffer
+	if err = Generate(&buf, tables, Options{Package: "main", CRUD: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "models.go"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(crudMain), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the generated code: %v\n%s\n%s", err, out, buf.String())
+	}
+	if want := "1 ann!\n2 bob!\n"; string(out) != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
@@ -0,0 +1,133 @@
+// Command sqlxgen generates Go structs for the tables of a database.
+//
+// It reads the tables and columns of a sqlite3, mysql or postgres database and
+// writes a struct with db tags for each table.  Nullable columns use the
+// sql.Null types, or types.NullJSONText for json columns, and primary key
+// columns are tagged with the key option used by the update and delete
+// helpers of sqlx.  Columns generated by the database, such as autoincrement
+// keys, are tagged with the default option, so zero values are left out of
+// inserts.
+//
+// Usage:
+//
+//	sqlxgen -driver sqlite3 -dsn app.db -pkg models -o models/tables.go
+//
+// With -tablename each struct gets a TableName method, and with -crud the
+// functions Insert<Type>, Get<Type>, Update<Type> and Delete<Type>, which use
+// DB.InsertTable, DB.Get, DB.UpdateTable and DB.DeleteTable.  Insert<Type>
+// sets a generated integer key on its row where the driver reports it as the
+// LastInsertId.
+//
+// With -queries it generates typed methods for the queries of annotated .sql
+// files instead, see Query.  The queries are bound and prepared as the
+// generated code runs them, against the -driver and -dsn database or an
+// in-memory sqlite3 database created from -schemafile, to check them and find
+// their result columns.  As database/sql only reports the columns of a query
+// by running it, :one and :many queries are run, in a transaction which is
+// rolled back.  Effects outside of it, such as those on sequences, remain, so
+// -dsn must then be a scratch database.  A result column selected as is keeps
+// the nullability of its table column, unless the query has an outer join;
+// other result columns are nullable unless the driver reports otherwise:
+//
+//	sqlxgen -schemafile schema.sql -queries 'queries/*.sql' -pkg db -o db/queries.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tietang/sqlx"
+)
+
+func main() {
+	var (
+		driver    = flag.String("driver", "sqlite3", "database driver: sqlite3, mysql or postgres")
+		dsn       = flag.String("dsn", "", "data source name of the database; with -queries, a scratch database, as queries are run to find their columns")
+		schema    = flag.String("schema", "", "schema to read, public for postgres and the current database for mysql by default")
+		tables    = flag.String("tables", "", "comma separated tables to generate, all tables by default")
+		pkg       = flag.String("pkg", "models", "package name of the generated file")
+		out       = flag.String("o", "", "output file, standard output by default")
+		tableName = flag.Bool("tablename", false, "generate TableName methods")
+		crud      = flag.Bool("crud", false, "generate insert, get, update and delete functions")
+		queries   = flag.String("queries", "", "comma separated patterns of annotated .sql files to generate query methods for")
+		schemaSQL = flag.String("schemafile", "", "schema of the queries, loaded into an in-memory sqlite3 database")
+	)
+	flag.Parse()
+
+	var db *sqlx.DB
+	var err error
+	switch {
+	case *queries != "" && *schemaSQL != "":
+		db, err = schemaDB(*schemaSQL)
+	case *dsn == "":
+		fmt.Fprintln(os.Stderr, "sqlxgen: -dsn is required")
+		flag.Usage()
+		os.Exit(2)
+	default:
+		db, err = sqlx.Connect(*driver, *dsn)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+
+	var only []string
+	if *tables != "" && *queries == "" {
+		only = strings.Split(*tables, ",")
+	}
+	ts, err := loadTables(db, *schema, only)
+	if err != nil {
+		fatal(err)
+	}
+
+	var buf bytes.Buffer
+	options := Options{Package: *pkg, TableName: *tableName, CRUD: *crud}
+	if *queries != "" {
+		var qs []Query
+		qs, err = loadQueries(context.Background(), db, ts, strings.Split(*queries, ","))
+		if err == nil {
+			err = GenerateQueries(&buf, qs, ts, options)
+		}
+	} else {
+		err = Generate(&buf, ts, options)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = ioutil.WriteFile(*out, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// schemaDB returns an in-memory sqlite3 database with the schema of file.
+func schemaDB(file string) (*sqlx.DB, error) {
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// every connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	if _, err = sqlx.LoadFile(db, file); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "sqlxgen:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/tietang/sqlx"
+)
+
+// Query is a query read from an annotated .sql file:
+//
+//	-- name: GetUser :one
+//	SELECT id, name FROM users WHERE id = :id
+//
+// Queries returning a single row are annotated :one, those returning any
+// number of rows :many, and statements returning no rows :exec.  Parameters
+// are either named, eg. :id, or positional, eg. ?, but not both.
+type Query struct {
+	Name string
+	Kind string
+	SQL  string
+	File string
+	// Params are the names of the named parameters, in order of appearance.
+	Params []string
+	// NumArgs is the number of positional parameters.
+	NumArgs int
+	// Columns are the result columns of :one and :many queries.
+	Columns []Column
+}
+
+var nameComment = regexp.MustCompile(`^--\s*name:\s*(\w+)\s+:(one|many|exec)\s*$`)
+
+// parseQueries reads the annotated queries of a .sql file.
+func parseQueries(file string, r io.Reader) ([]Query, error) {
+	var queries []Query
+	var buf strings.Builder
+	flush := func() error {
+		if len(queries) == 0 {
+			if strings.TrimSpace(buf.String()) != "" && !onlyComments(buf.String()) {
+				return fmt.Errorf("%s: statement before the first -- name: comment", file)
+			}
+			buf.Reset()
+			return nil
+		}
+		q := &queries[len(queries)-1]
+		q.SQL = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(buf.String()), ";"))
+		buf.Reset()
+		if q.SQL == "" {
+			return fmt.Errorf("%s: query %s is empty", file, q.Name)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(text), "-- name:") {
+			m := nameComment.FindStringSubmatch(strings.TrimSpace(text))
+			if m == nil {
+				return nil, fmt.Errorf("%s:%d: expected -- name: <Name> :one|:many|:exec", file, line)
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			queries = append(queries, Query{Name: m[1], Kind: m[2], File: filepath.Base(file)})
+			continue
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+func onlyComments(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// parameters returns the named parameters and the number of positional
+// parameters of a query.  Named parameters are found as sqlx.Named finds them,
+// which includes those in string literals and comments, so that analyze
+// reports them as missing from the prepared query.  Positional parameters in
+// string literals, quoted identifiers and comments are skipped.
+func parameters(query string) ([]string, int) {
+	var names []string
+	seen := map[string]bool{}
+	for i := 0; i < len(query); i++ {
+		if query[i] != ':' || i+1 == len(query) {
+			continue
+		}
+		if query[i+1] == ':' || query[i+1] == '=' {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(query) && isNameByte(query[j]) {
+			j++
+		}
+		if name := query[i+1 : j]; name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		i = j - 1
+	}
+
+	positional := 0
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(query) && query[i] != c; i++ {
+			}
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			for ; i < len(query) && query[i] != '\n'; i++ {
+			}
+		case c == '?':
+			positional++
+		}
+	}
+	return names, positional
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// analyze prepares q against db, a database with the schema of the queries,
+// to check it and to read its result columns.  Columns of tables in tables
+// are typed as in their table.  Columns are nullable unless the driver
+// reports otherwise, as the result of an expression or an outer join may be
+// NULL whatever its table says.
+func analyze(ctx context.Context, db *sqlx.DB, tables []Table, q *Query) error {
+	q.Params, q.NumArgs = parameters(q.SQL)
+	if len(q.Params) > 0 && q.NumArgs > 0 {
+		return fmt.Errorf("%s: query %s mixes named and positional parameters", q.File, q.Name)
+	}
+	for _, p := range q.Params {
+		if strings.Contains(p, ".") {
+			return fmt.Errorf("%s: query %s has a nested parameter %s", q.File, q.Name, p)
+		}
+	}
+
+	query, args, err := bound(db, q)
+	if err != nil {
+		return fmt.Errorf("%s: query %s: %v", q.File, q.Name, err)
+	}
+	n, err := numInput(ctx, db, query)
+	if err != nil {
+		return fmt.Errorf("%s: query %s: %v", q.File, q.Name, err)
+	}
+	if want := len(q.Params) + q.NumArgs; n >= 0 && n != want {
+		return fmt.Errorf("%s: query %s has %d parameters, found %d", q.File, q.Name, n, want)
+	}
+	if q.Kind == "exec" {
+		return nil
+	}
+
+	// database/sql only reports the columns of a query once it runs.  It
+	// runs in a transaction in case it writes, but changes outside of it,
+	// such as sequences, remain;  hence -dsn must be a scratch database
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: query %s: %v", q.File, q.Name, err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	if len(types) == 0 {
+		return fmt.Errorf("%s: query %s returns no columns, annotate it :exec", q.File, q.Name)
+	}
+	for _, ct := range types {
+		c, ok := findColumn(tables, q.SQL, ct.Name())
+		if !ok {
+			c = Column{Name: ct.Name(), Type: ct.DatabaseTypeName()}
+		}
+		// only a column selected as is keeps the nullability of its table;
+		// expressions, aliases and outer joins may be NULL
+		if !ok || !selectsColumn(q.SQL, ct.Name()) || outerJoin.MatchString(q.SQL) {
+			c.Nullable = true
+		}
+		// sqlite3 reports every column as nullable
+		if nullable, ok := ct.Nullable(); ok && db.DriverName() != "sqlite3" {
+			c.Nullable = nullable
+		}
+		c.Key, c.AutoIncrement = false, false
+		q.Columns = append(q.Columns, c)
+	}
+	return nil
+}
+
+// bound returns q as the generated code sends it to the driver of db: with
+// its named parameters compiled by sqlx.Named and rebound, and a nil argument
+// for every parameter.
+func bound(db *sqlx.DB, q *Query) (string, []interface{}, error) {
+	query, args := q.SQL, make([]interface{}, q.NumArgs)
+	if len(q.Params) > 0 {
+		arg := make(map[string]interface{}, len(q.Params))
+		for _, p := range q.Params {
+			arg[p] = nil
+		}
+		var err error
+		if query, args, err = sqlx.Named(q.SQL, arg); err != nil {
+			return "", nil, err
+		}
+	}
+	return db.Rebind(query), args, nil
+}
+
+// numInput prepares query with the driver of db and returns its number of
+// parameters, or -1 if the driver does not know it.
+func numInput(ctx context.Context, db *sqlx.DB, query string) (int, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	n := -1
+	err = conn.Raw(func(dc interface{}) error {
+		stmt, err := dc.(driver.Conn).Prepare(query)
+		if err != nil {
+			return err
+		}
+		n = stmt.NumInput()
+		return stmt.Close()
+	})
+	return n, err
+}
+
+// findColumn returns the column named name of the tables, preferring the
+// tables named in the query.
+func findColumn(tables []Table, query, name string) (Column, bool) {
+	var found *Column
+	for i := range tables {
+		for j, c := range tables[i].Columns {
+			if !strings.EqualFold(c.Name, name) {
+				continue
+			}
+			if mentions(query, tables[i].Name) {
+				return tables[i].Columns[j], true
+			}
+			if found == nil {
+				found = &tables[i].Columns[j]
+			}
+		}
+	}
+	if found == nil {
+		return Column{}, false
+	}
+	return *found, true
+}
+
+// outerJoin matches queries with an outer join, whose columns may be NULL.
+var outerJoin = regexp.MustCompile(`(?i)\b(left|right|full)\s+(outer\s+)?join\b`)
+
+// selectsColumn reports whether query selects the column name as is, eg.
+// "SELECT id, u.name FROM", or all columns with "SELECT *", rather than an
+// expression or alias of that name.
+func selectsColumn(query, name string) bool {
+	re := regexp.MustCompile(`(?is)(\bselect\s+(distinct\s+)?|,)\s*([\w"]+\.)?("` + regexp.QuoteMeta(name) + `"|\b` + regexp.QuoteMeta(name) + `\b)\s*(,|\bfrom\b)`)
+	return re.MatchString(query) || selectStar.MatchString(query)
+}
+
+// selectStar matches queries selecting all columns of a table.
+var selectStar = regexp.MustCompile(`(?is)(\bselect\s+(distinct\s+)?|,)\s*([\w"]+\.)?\*\s*(,|\bfrom\b)`)
+
+func mentions(query, table string) bool {
+	re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(table) + `\b`)
+	return re.MatchString(query)
+}
+
+// loadQueries reads and analyzes the queries of the .sql files matching the
+// patterns.
+func loadQueries(ctx context.Context, db *sqlx.DB, tables []Table, patterns []string) ([]Query, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .sql files match %s", strings.Join(patterns, ", "))
+	}
+	sort.Strings(files)
+
+	var queries []Query
+	names := map[string]string{}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		qs, err := parseQueries(file, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		for i := range qs {
+			if other, ok := names[qs[i].Name]; ok {
+				return nil, fmt.Errorf("%s: query %s is also defined in %s", qs[i].File, qs[i].Name, other)
+			}
+			names[qs[i].Name] = qs[i].File
+			if err = analyze(ctx, db, tables, &qs[i]); err != nil {
+				return nil, err
+			}
+		}
+		queries = append(queries, qs...)
+	}
+	return queries, nil
+}
+
+// queryDef is a method generated for a query.
+type queryDef struct {
+	Query
+	Const  string
+	Params []fieldDef
+	Args   []string
+	Fields []fieldDef
+}
+
+// GenerateQueries writes the Go source of the methods for queries to w.
+// Named parameters are typed after the columns with the same name in tables.
+func GenerateQueries(w io.Writer, queries []Query, tables []Table, options Options) error {
+	imports := map[string]bool{
+		"context":                 true,
+		"github.com/tietang/sqlx": true,
+	}
+	defs := make([]queryDef, 0, len(queries))
+	for _, q := range queries {
+		d := queryDef{Query: q, Const: strings.ToLower(q.Name[:1]) + q.Name[1:]}
+		names := map[string]bool{}
+		for _, p := range q.Params {
+			c, _ := findColumn(tables, q.SQL, p)
+			typ, pkg := goType(c)
+			if pkg != "" {
+				imports[pkg] = true
+			}
+			f := fieldDef{Name: exportedName(p), Type: typ, Tag: p}
+			if names[f.Name] {
+				return fmt.Errorf("%s: query %s has parameters with the same field name %s", q.File, q.Name, f.Name)
+			}
+			names[f.Name] = true
+			d.Params = append(d.Params, f)
+		}
+		for i := 1; i <= q.NumArgs; i++ {
+			d.Args = append(d.Args, fmt.Sprintf("arg%d", i))
+		}
+		names = map[string]bool{}
+		for _, c := range q.Columns {
+			typ, pkg := goType(c)
+			if pkg != "" {
+				imports[pkg] = true
+			}
+			f := fieldDef{Name: exportedName(c.Name), Type: typ, Tag: c.Name}
+			if names[f.Name] {
+				return fmt.Errorf("%s: query %s returns columns with the same field name %s", q.File, q.Name, f.Name)
+			}
+			names[f.Name] = true
+			d.Fields = append(d.Fields, f)
+		}
+		if q.Kind == "exec" {
+			imports["database/sql"] = true
+		}
+		defs = append(defs, d)
+	}
+	var paths []string
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	err := queriesTemplate.Execute(&buf, struct {
+		Options
+		Imports []string
+		Queries []queryDef
+	}{options, paths, defs})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("formatting generated code: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+var queriesTemplate = template.Must(template.New("queries").Parse(`// Code generated by sqlxgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .Imports}}	"{{.}}"
+{{end}})
+
+// Queries runs the generated queries on a database or transaction.
+type Queries struct {
+	db sqlx.ExtContext
+}
+
+// New returns the Queries of db, a *sqlx.DB or *sqlx.Tx.
+func New(db sqlx.ExtContext) *Queries {
+	return &Queries{db: db}
+}
+{{range .Queries}}
+const {{.Const}} = {{printf "%q" .SQL}}
+{{if .Params}}
+// {{.Name}}Params are the parameters of {{.Name}}.
+type {{.Name}}Params struct {
+{{range .Params}}	{{.Name}} {{.Type}} ` + "`" + `db:"{{.Tag}}"` + "`" + `
+{{end}}}
+{{end}}{{if .Fields}}
+// {{.Name}}Row is a row returned by {{.Name}}.
+type {{.Name}}Row struct {
+{{range .Fields}}	{{.Name}} {{.Type}} ` + "`" + `db:"{{.Tag}}"` + "`" + `
+{{end}}}
+{{end}}
+// {{.Name}} runs the query {{.Name}} of {{.File}}.
+func (q *Queries) {{.Name}}(ctx context.Context{{if .Params}}, arg {{.Name}}Params{{end}}{{range .Args}}, {{.}} interface{}{{end}}) (
+{{- if eq .Kind "one"}}*{{.Name}}Row{{else if eq .Kind "many"}}[]{{.Name}}Row{{else}}sql.Result{{end}}, error) {
+{{- if eq .Kind "exec"}}
+{{- if .Params}}
+	return sqlx.NamedExecContext(ctx, q.db, {{.Const}}, arg)
+{{- else}}
+	return q.db.ExecContext(ctx, q.db.Rebind({{.Const}}){{range .Args}}, {{.}}{{end}})
+{{- end}}
+{{- else}}
+{{- if .Params}}
+	query, args, err := sqlx.Named({{.Const}}, arg)
+	if err != nil {
+		return nil, err
+	}
+{{- else}}
+	query, args := {{.Const}}, []interface{}{ {{- range $i, $a := .Args}}{{if $i}}, {{end}}{{$a}}{{end -}} }
+{{- end}}
+{{- if eq .Kind "one"}}
+	var row {{.Name}}Row
+	if err := sqlx.GetContext(ctx, q.db, &row, q.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return &row, nil
+{{- else}}
+	var rows []{{.Name}}Row
+	if err := sqlx.SelectContext(ctx, q.db, &rows, q.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+{{- end}}
+{{- end}}
+}
+{{end}}`))
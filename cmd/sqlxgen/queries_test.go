@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tietang/sqlx"
+)
+
+const testQueries = `-- queries of the users table
+
+-- name: GetUser :one
+SELECT id, name, email FROM users WHERE id = :id;
+
+-- name: ListUsers :many
+SELECT id, name, length(email) AS total
+FROM users
+WHERE name LIKE ? -- a ? in a comment
+ORDER BY id;
+
+-- name: RenameUser :exec
+UPDATE users SET name = :name WHERE id = :id AND name <> '';
+
+-- name: ListPairs :many
+SELECT a.id, b.name FROM users a LEFT JOIN users b ON b.id = a.id + 1
+`
+
+func TestParseQueries(t *testing.T) {
+	queries, err := parseQueries("users.sql", strings.NewReader(testQueries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 4 {
+		t.Fatalf("expected 4 queries, got %#v", queries)
+	}
+	if q := queries[0]; q.Name != "GetUser" || q.Kind != "one" || q.SQL != "SELECT id, name, email FROM users WHERE id = :id" {
+		t.Errorf("unexpected query %#v", q)
+	}
+	if q := queries[2]; q.Kind != "exec" || !strings.HasPrefix(q.SQL, "UPDATE users") {
+		t.Errorf("unexpected query %#v", q)
+	}
+
+	bad := []string{
+		"SELECT 1;\n-- name: One :one\nSELECT 1",
+		"-- name: One :first\nSELECT 1",
+		"-- name: One :one\n\n-- name: Two :one\nSELECT 2",
+	}
+	for _, src := range bad {
+		if _, err = parseQueries("bad.sql", strings.NewReader(src)); err == nil {
+			t.Errorf("expected an error parsing %q", src)
+		}
+	}
+}
+
+func TestParameters(t *testing.T) {
+	tests := []struct {
+		query      string
+		names      []string
+		positional int
+	}{
+		{"SELECT * FROM t WHERE a = :a AND b = :b OR a = :a", []string{"a", "b"}, 0},
+		{"SELECT * FROM t WHERE a = ? AND b = ?", nil, 2},
+		{"SELECT '?', \"?\", a::text, b := 1 FROM t -- ?\nWHERE c = :c_1", []string{"c_1"}, 0},
+		{"SELECT ':x' FROM t", []string{"x"}, 0},
+	}
+	for _, test := range tests {
+		names, positional := parameters(test.query)
+		if !reflect.DeepEqual(names, test.names) || positional != test.positional {
+			t.Errorf("expected %v and %d for %q, got %v and %d", test.names, test.positional, test.query, names, positional)
+		}
+	}
+}
+
+func TestBound(t *testing.T) {
+	db := sqlx.NewDb(nil, "postgres")
+	tests := []struct {
+		q     Query
+		query string
+		args  int
+	}{
+		{Query{SQL: "SELECT * FROM t WHERE a = :a AND b = :b OR a = :a", Params: []string{"a", "b"}}, "SELECT * FROM t WHERE a = $1 AND b = $2 OR a = $3", 3},
+		{Query{SQL: "SELECT * FROM t WHERE a = ? AND b = ?", NumArgs: 2}, "SELECT * FROM t WHERE a = $1 AND b = $2", 2},
+	}
+	for _, test := range tests {
+		query, args, err := bound(db, &test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != test.query || len(args) != test.args {
+			t.Errorf("expected %q with %d args, got %q with %d", test.query, test.args, query, len(args))
+		}
+	}
+}
+
+func TestGenerateQueries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlxgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schema := filepath.Join(dir, "schema.sql")
+	err = ioutil.WriteFile(schema, []byte(`CREATE TABLE users (id integer PRIMARY KEY, name text NOT NULL, email text);`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryDir := filepath.Join(dir, "queries")
+	if err = os.Mkdir(queryDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(queryDir, "users.sql"), []byte(testQueries), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := schemaDB(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tables, err := loadTables(db, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	queries, err := loadQueries(ctx, db, tables, []string{filepath.Join(queryDir, "*.sql")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// columns selected as is keep the nullability of the table, expressions
+	// are nullable, and so is every column of an outer join
+	if q := queries[1]; q.NumArgs != 1 || len(q.Columns) != 3 || q.Columns[1].Type != "text" || q.Columns[1].Nullable || !q.Columns[2].Nullable {
+		t.Errorf("unexpected analysis of ListUsers %#v", q)
+	}
+	if q := queries[3]; len(q.Columns) != 2 || !q.Columns[0].Nullable || !q.Columns[1].Nullable {
+		t.Errorf("unexpected analysis of ListPairs %#v", q)
+	}
+	if q := queries[2]; !reflect.DeepEqual(q.Params, []string{"name", "id"}) {
+		t.Errorf("unexpected parameters of RenameUser %#v", q.Params)
+	}
+
+	var buf bytes.Buffer
+	if err = GenerateQueries(&buf, queries, tables, Options{Package: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+	if _, err = parser.ParseFile(token.NewFileSet(), "queries.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		"func New(db sqlx.ExtContext) *Queries",
+		"func (q *Queries) GetUser(ctx context.Context, arg GetUserParams) (*GetUserRow, error)",
+		"type GetUserRow struct { ID int64 `db:\"id\"` Name string `db:\"name\"` Email sql.NullString `db:\"email\"` }",
+		"type ListPairsRow struct { ID sql.NullInt64 `db:\"id\"` Name sql.NullString `db:\"name\"` }",
+		"func (q *Queries) ListUsers(ctx context.Context, arg1 interface{}) ([]ListUsersRow, error)",
+		"Total interface{} `db:\"total\"`",
+		"return sqlx.NamedExecContext(ctx, q.db, renameUser, arg)",
+		"Name string `db:\"name\"`",
+	} {
+		// gofmt aligns the fields of structs, compare with single spaces
+		if !strings.Contains(strings.Join(strings.Fields(src), " "), want) {
+			t.Errorf("expected the generated code to contain %s, got\n%s", want, src)
+		}
+	}
+
+	// queries are checked against the schema, and named parameters in string
+	// literals, which sqlx would bind, are reported
+	for _, bad := range []string{
+		"-- name: Bad :one\nSELECT missing FROM users",
+		"-- name: Bad :exec\nUPDATE users SET name = ':name'",
+	} {
+		if err = ioutil.WriteFile(filepath.Join(queryDir, "bad.sql"), []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = loadQueries(ctx, db, tables, []string{filepath.Join(queryDir, "*.sql")}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestSelectsColumn(t *testing.T) {
+	tests := []struct {
+		query, name string
+		want        bool
+	}{
+		{"SELECT id, name FROM users", "name", true},
+		{"SELECT u.id, u.\"name\"\nFROM users u", "name", true},
+		{"SELECT DISTINCT name FROM users", "name", true},
+		{"SELECT * FROM users", "name", true},
+		{"SELECT id, upper(name) AS name FROM users", "name", false},
+		{"SELECT id, email AS name FROM users", "name", false},
+		{"SELECT id, length(name) total FROM users", "total", false},
+	}
+	for _, test := range tests {
+		if got := selectsColumn(test.query, test.name); got != test.want {
+			t.Errorf("expected %v for %s in %q, got %v", test.want, test.name, test.query, got)
+		}
+	}
+}
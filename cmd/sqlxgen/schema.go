@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tietang/sqlx"
+)
+
+// Table is a table of the database.
+type Table struct {
+	Name    string
+	Columns []Column
+}
+
+// Column is a column of a table.  Type is the type declared in the database,
+// eg. "varchar(255)" or "timestamp with time zone".  AutoIncrement is set for
+// columns whose values the database generates on insert, such as sqlite's
+// INTEGER PRIMARY KEY, mysql's auto_increment and postgres' serial and
+// identity columns.
+type Column struct {
+	Name          string `db:"column_name"`
+	Type          string `db:"data_type"`
+	Nullable      bool   `db:"nullable"`
+	Key           bool   `db:"primary_key"`
+	AutoIncrement bool   `db:"auto_increment"`
+}
+
+// loadTables reads the tables of db in schema, or only the tables in only if
+// it is not empty, sorted by name.
+func loadTables(db *sqlx.DB, schema string, only []string) ([]Table, error) {
+	var tables []Table
+	var err error
+	switch db.DriverName() {
+	case "sqlite3":
+		tables, err = sqliteTables(db)
+	case "mysql":
+		tables, err = informationSchemaTables(db, schema, mysqlColumns)
+	case "postgres", "pgx":
+		if schema == "" {
+			schema = "public"
+		}
+		tables, err = informationSchemaTables(db, schema, postgresColumns)
+	default:
+		return nil, fmt.Errorf("unsupported driver %s", db.DriverName())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(only) > 0 {
+		byName := map[string]Table{}
+		for _, t := range tables {
+			byName[t.Name] = t
+		}
+		tables = tables[:0]
+		for _, name := range only {
+			t, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("no table %s", name)
+			}
+			tables = append(tables, t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
+
+func sqliteTables(db *sqlx.DB) ([]Table, error) {
+	var names []struct {
+		Name string `db:"name"`
+	}
+	err := db.Select(&names, `SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]Table, 0, len(names))
+	for _, n := range names {
+		name := n.Name
+		rows, err := db.Queryx(fmt.Sprintf("PRAGMA table_info(%q)", name))
+		if err != nil {
+			return nil, err
+		}
+		t := Table{Name: name}
+		keys := 0
+		for rows.Next() {
+			var c Column
+			var cid, notNull, pk int
+			var def interface{}
+			if err = rows.Scan(&cid, &c.Name, &c.Type, &notNull, &def, &pk); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			c.Nullable, c.Key = notNull == 0 && pk == 0, pk > 0
+			if c.Key {
+				keys++
+			}
+			t.Columns = append(t.Columns, c)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		// a single INTEGER PRIMARY KEY column is an alias of the rowid
+		for i, c := range t.Columns {
+			if c.Key && keys == 1 && strings.EqualFold(c.Type, "integer") {
+				t.Columns[i].AutoIncrement = true
+			}
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// mysqlColumns reads the columns of the tables of a schema, or of the current
+// database if the schema is empty.
+const mysqlColumns = `
+SELECT c.table_name AS table_name, c.column_name AS column_name, c.column_type AS data_type,
+	c.is_nullable = 'YES' AS nullable, c.column_key = 'PRI' AS primary_key,
+	c.extra LIKE '%auto_increment%' AS auto_increment
+FROM information_schema.columns c
+JOIN information_schema.tables t
+	ON t.table_schema = c.table_schema AND t.table_name = c.table_name AND t.table_type = 'BASE TABLE'
+WHERE c.table_schema = COALESCE(NULLIF(?, ''), DATABASE())
+ORDER BY c.table_name, c.ordinal_position`
+
+// postgresColumns reads the columns of the tables of a schema.
+const postgresColumns = `
+SELECT c.table_name AS table_name, c.column_name AS column_name, c.data_type AS data_type,
+	c.is_nullable = 'YES' AS nullable,
+	EXISTS (
+		SELECT 1
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage k
+			ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema
+			AND tc.table_name = c.table_name AND k.column_name = c.column_name
+	) AS primary_key,
+	COALESCE(c.column_default, '') LIKE 'nextval(%' OR COALESCE(c.is_identity, 'NO') = 'YES' AS auto_increment
+FROM information_schema.columns c
+JOIN information_schema.tables t
+	ON t.table_schema = c.table_schema AND t.table_name = c.table_name AND t.table_type = 'BASE TABLE'
+WHERE c.table_schema = ?
+ORDER BY c.table_name, c.ordinal_position`
+
+func informationSchemaTables(db *sqlx.DB, schema, query string) ([]Table, error) {
+	var columns []struct {
+		Table string `db:"table_name"`
+		Column
+	}
+	if err := db.Select(&columns, db.Rebind(query), schema); err != nil {
+		return nil, err
+	}
+
+	var tables []Table
+	for _, c := range columns {
+		if len(tables) == 0 || tables[len(tables)-1].Name != c.Table {
+			tables = append(tables, Table{Name: c.Table})
+		}
+		t := &tables[len(tables)-1]
+		t.Columns = append(t.Columns, c.Column)
+	}
+	return tables, nil
+}
@@ -0,0 +1,59 @@
+package sqlx
+
+import (
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+)
+
+// Column options controlling how fields are written:
+//
+//  * readonly - the column is never written, eg. a generated column.  Key
+//    columns tagged readonly are still mapped on updates and deletes to find
+//    the row, but not on inserts.
+//  * scanonly - like readonly;  "-,scanonly" maps the field under its untagged
+//    name for scanning only.
+//  * insertonly - the column is written on insert and left out of updates.
+//  * default - the column is left out of writes when the field is zero, so the
+//    database keeps or fills in its default.
+//
+// MapOptions.IncludeReadOnly, IncludeInsertOnly and IncludeDefault override
+// these per call.
+
+// writable reports whether the column of fi is written by a write of the kind
+// options.Op.
+func writable(fi *reflectx.FieldInfo, options *MapOptions) bool {
+    _, key := fi.Options["key"]
+    _, readOnly := fi.Options["readonly"]
+    _, scanOnly := fi.Options["scanonly"]
+    _, insertOnly := fi.Options["insertonly"]
+    switch {
+    case (readOnly || scanOnly) && !options.IncludeReadOnly:
+        return key && options.Op != MapInsert
+    case insertOnly && options.Op == MapUpdate && !options.IncludeInsertOnly:
+        return key
+    }
+    return true
+}
+
+// defaulted reports whether the column of fi is left to its database default
+// when the field is zero.
+func defaulted(fi *reflectx.FieldInfo, options *MapOptions) bool {
+    _, key := fi.Options["key"]
+    _, ok := fi.Options["default"]
+    return ok && !options.IncludeDefault && (!key || options.Op == MapInsert)
+}
+
+// isZeroValue reports whether the value fld of the field fi is zero, as
+// understood by the omitempty and default options.  Nil pointers are zero.
+func isZeroValue(fld reflect.Value, fi *reflectx.FieldInfo) bool {
+    if fld.Kind() == reflect.Ptr && fld.IsNil() {
+        return true
+    }
+    if t, ok := fld.Interface().(hasIsZero); ok {
+        return t.IsZero()
+    }
+    if fld.Kind() == reflect.Array || fld.Kind() == reflect.Slice {
+        return fld.Len() == 0
+    }
+    return reflect.DeepEqual(fi.Zero.Interface(), fld.Interface())
+}
@@ -1,7 +1,10 @@
 package sqlx
 
 import (
+    "database/sql"
     "errors"
+    "fmt"
+    "upper.io/db.v3"
 )
 
 // Common error messages.
@@ -11,7 +14,68 @@ var (
     ErrExpectingSliceMapStruct             = errors.New(`argument must be a slice address of maps or structs`)
     ErrExpectingMapOrStruct                = errors.New(`argument must be either a map or a struct`)
     ErrExpectingPointerToEitherMapOrStruct = errors.New(`expecting a pointer to either a map or a struct`)
+    // ErrStaleObject is returned by updates of structs with a version column
+    // when the row was changed, or deleted, since the struct was read.
+    ErrStaleObject = errors.New(`sqlx: stale object, the row was modified concurrently`)
+    // ErrNotTracked is returned by UpdateChanged for structs which were not
+    // passed to Track.
+    ErrNotTracked = errors.New(`sqlx: struct is not tracked, call Track after scanning it`)
 )
 var (
     errDeprecatedJSONBTag = errors.New(`Tag "jsonb" is deprecated. See "PostgreSQL: jsonb tag" at https://github.com/upper/db/releases/tag/v3.4.0`)
 )
+
+// QueryError is the error returned by sqlx query and exec functions.  It wraps
+// the error returned by the driver (or by binding and scanning) with the query
+// which caused it.  Use Unwrap, errors.Is or errors.As to get at the original
+// error.  sql.ErrNoRows is never wrapped, so it can still be compared directly.
+type QueryError struct {
+    // Op is the sqlx operation which failed, eg. "select", "get" or "exec".
+    Op string
+    // Query is the query as sent to the driver.
+    Query    string
+    BindType int
+    NumArgs  int
+    // Args are the query arguments.  They are nil unless the query was run
+    // on a handle returned by ErrorArgs; only NumArgs is reported otherwise.
+    Args []interface{}
+    Err  error
+}
+
+func (e *QueryError) Error() string {
+    if inner, ok := e.Err.(*QueryError); ok && inner.Query == e.Query {
+        return fmt.Sprintf("sqlx: %s: %v", e.Op, inner)
+    }
+    if len(e.Args) == 0 {
+        return fmt.Sprintf("sqlx: %s %q with %d args: %v", e.Op, e.Query, e.NumArgs, e.Err)
+    }
+    return fmt.Sprintf("sqlx: %s %q with args %v: %v", e.Op, e.Query, e.Args, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *QueryError) Unwrap() error {
+    return e.Err
+}
+
+// queryError wraps err in a *QueryError for op.  The bindtype is taken from e
+// if it knows its driver.  The no rows sentinels are returned as they are, and
+// errors which are already QueryErrors are wrapped in one for the outer op,
+// eg. a failing DB.Queryx called by Select is reported as a "select".  Args
+// are only kept when e was returned by ErrorArgs.
+func queryError(op string, e interface{}, query string, args []interface{}, err error) error {
+    switch err {
+    case nil, sql.ErrNoRows, db.ErrNoMoreRows:
+        return err
+    }
+    if inner, ok := err.(*QueryError); ok {
+        return &QueryError{Op: op, Query: inner.Query, BindType: inner.BindType, NumArgs: inner.NumArgs, Args: inner.Args, Err: inner}
+    }
+    qe := &QueryError{Op: op, Query: query, BindType: UNKNOWN, NumArgs: len(args), Err: err}
+    if b, ok := e.(binder); ok {
+        qe.BindType = BindType(b.DriverName())
+    }
+    if hasErrorArgs(e) && len(args) > 0 {
+        qe.Args = append([]interface{}(nil), args...)
+    }
+    return qe
+}
@@ -0,0 +1,170 @@
+package sqlx
+
+import (
+    "database/sql/driver"
+    "net"
+    "reflect"
+    "regexp"
+    "strings"
+    "sync"
+)
+
+// ErrorKind is a driver independent classification of a database error.
+type ErrorKind int
+
+// Error kinds reported by ClassifyError.
+const (
+    UnknownError ErrorKind = iota
+    UniqueViolation
+    ForeignKeyViolation
+    NotNullViolation
+    Deadlock
+    SerializationFailure
+    LockTimeout
+    ConnectionError
+)
+
+var errorKindNames = map[ErrorKind]string{
+    UnknownError:         "unknown error",
+    UniqueViolation:      "unique violation",
+    ForeignKeyViolation:  "foreign key violation",
+    NotNullViolation:     "not null violation",
+    Deadlock:             "deadlock",
+    SerializationFailure: "serialization failure",
+    LockTimeout:          "lock timeout",
+    ConnectionError:      "connection error",
+}
+
+func (k ErrorKind) String() string {
+    return errorKindNames[k]
+}
+
+// ErrorClass describes a database error in driver independent terms.  The
+// Constraint, Table and Column are filled in where the driver reports them,
+// either directly or in its error message.
+type ErrorClass struct {
+    Kind ErrorKind
+    // Code is the driver's error code, eg. "23505" for postgres, "1062" for
+    // mysql or "2067" for the extended sqlite3 code.
+    Code       string
+    Constraint string
+    Table      string
+    Column     string
+}
+
+// An ErrorClassifier classifies errors of a particular driver, returning false
+// if it does not recognize err.
+type ErrorClassifier func(err error) (ErrorClass, bool)
+
+var (
+    classifiersMu    sync.RWMutex
+    errorClassifiers []ErrorClassifier
+)
+
+// RegisterErrorClassifier adds a classifier consulted by ClassifyError.  The
+// errors of the lib/pq, go-sql-driver/mysql and go-sqlite3 drivers are
+// classified by default, without importing the drivers.
+func RegisterErrorClassifier(c ErrorClassifier) {
+    classifiersMu.Lock()
+    errorClassifiers = append(errorClassifiers, c)
+    classifiersMu.Unlock()
+}
+
+// ClassifyError returns the classification of err.  Wrapped errors, such as
+// a *QueryError, are unwrapped until a registered classifier recognizes one.
+func ClassifyError(err error) ErrorClass {
+    classifiersMu.RLock()
+    classifiers := errorClassifiers
+    classifiersMu.RUnlock()
+
+    for err != nil {
+        for _, classify := range classifiers {
+            if class, ok := classify(err); ok {
+                return class
+            }
+        }
+        if err == driver.ErrBadConn {
+            return ErrorClass{Kind: ConnectionError}
+        }
+        if _, ok := err.(net.Error); ok {
+            return ErrorClass{Kind: ConnectionError}
+        }
+        u, ok := err.(interface{ Unwrap() error })
+        if !ok {
+            break
+        }
+        err = u.Unwrap()
+    }
+    return ErrorClass{Kind: UnknownError}
+}
+
+// IsUniqueViolation returns true if err is a unique or primary key violation.
+func IsUniqueViolation(err error) bool {
+    return ClassifyError(err).Kind == UniqueViolation
+}
+
+// IsForeignKeyViolation returns true if err is a foreign key violation.
+func IsForeignKeyViolation(err error) bool {
+    return ClassifyError(err).Kind == ForeignKeyViolation
+}
+
+// IsNotNullViolation returns true if err is a not null violation.
+func IsNotNullViolation(err error) bool {
+    return ClassifyError(err).Kind == NotNullViolation
+}
+
+// IsDeadlock returns true if err reports that the transaction was chosen as
+// a deadlock victim.
+func IsDeadlock(err error) bool {
+    return ClassifyError(err).Kind == Deadlock
+}
+
+// IsSerializationFailure returns true if err reports that a serializable
+// transaction could not be committed and should be retried.
+func IsSerializationFailure(err error) bool {
+    return ClassifyError(err).Kind == SerializationFailure
+}
+
+// IsLockTimeout returns true if err reports that a lock could not be acquired
+// in time.  Unlike deadlocks and serialization failures the statement may
+// have been waiting on a long running transaction, so retrying it is not
+// necessarily safe.
+func IsLockTimeout(err error) bool {
+    return ClassifyError(err).Kind == LockTimeout
+}
+
+// IsConnectionError returns true if err is caused by a broken or unusable
+// connection to the database.
+func IsConnectionError(err error) bool {
+    return ClassifyError(err).Kind == ConnectionError
+}
+
+// submatch returns the first submatch of re in s, or the empty string.
+func submatch(re *regexp.Regexp, s string) string {
+    m := re.FindStringSubmatch(s)
+    if len(m) < 2 {
+        return ""
+    }
+    return m[1]
+}
+
+// driverError returns the struct err is or points to if its type is name,
+// declared in the package path pkg, possibly vendored.  Classifiers use it to
+// read the fields of driver errors without importing the driver.
+func driverError(err error, pkg, name string) (reflect.Value, bool) {
+    v := reflect.ValueOf(err)
+    if v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            return reflect.Value{}, false
+        }
+        v = v.Elem()
+    }
+    t := v.Type()
+    if t.Kind() != reflect.Struct || t.Name() != name {
+        return reflect.Value{}, false
+    }
+    if path := t.PkgPath(); path != pkg && !strings.HasSuffix(path, "/vendor/"+pkg) {
+        return reflect.Value{}, false
+    }
+    return v, true
+}
@@ -0,0 +1,113 @@
+package sqlx
+
+import (
+	"database/sql/driver"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestClassifyError(t *testing.T) {
+	table := []struct {
+		err        error
+		kind       ErrorKind
+		constraint string
+		column     string
+	}{
+		{&pq.Error{Code: "23505", Constraint: "person_email_key"}, UniqueViolation, "person_email_key", ""},
+		{&pq.Error{Code: "23503", Constraint: "place_person_fk"}, ForeignKeyViolation, "place_person_fk", ""},
+		{&pq.Error{Code: "23502", Column: "email"}, NotNullViolation, "", "email"},
+		{&pq.Error{Code: "40P01"}, Deadlock, "", ""},
+		{&pq.Error{Code: "40001"}, SerializationFailure, "", ""},
+		{&pq.Error{Code: "55P03"}, LockTimeout, "", ""},
+		{&pq.Error{Code: "08006"}, ConnectionError, "", ""},
+		{&pq.Error{Code: "42601"}, UnknownError, "", ""},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'person.email'"}, UniqueViolation, "email", ""},
+		{&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails " +
+			"(`sqlxtest`.`place`, CONSTRAINT `place_person_fk` FOREIGN KEY (`person_id`) REFERENCES `person` (`id`))"},
+			ForeignKeyViolation, "place_person_fk", "person_id"},
+		{&mysql.MySQLError{Number: 1048, Message: "Column 'email' cannot be null"}, NotNullViolation, "", "email"},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, Deadlock, "", ""},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded; try restarting transaction"}, LockTimeout, "", ""},
+		{mysql.ErrInvalidConn, ConnectionError, "", ""},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, UniqueViolation, "", ""},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintNotNull}, NotNullViolation, "", ""},
+		{&sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}, UniqueViolation, "", ""},
+		{sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusyRecovery}, LockTimeout, "", ""},
+		{sqlite3.Error{Code: sqlite3.ErrLocked, ExtendedCode: sqlite3.ErrLockedSharedCache}, LockTimeout, "", ""},
+		{sqlite3.Error{Code: sqlite3.ErrBusy, ExtendedCode: sqlite3.ErrBusySnapshot}, SerializationFailure, "", ""},
+		{driver.ErrBadConn, ConnectionError, "", ""},
+		{&QueryError{Op: "exec", Err: &pq.Error{Code: "23505", Constraint: "person_email_key"}}, UniqueViolation, "person_email_key", ""},
+	}
+
+	for _, test := range table {
+		class := ClassifyError(test.err)
+		if class.Kind != test.kind {
+			t.Errorf("expected %v to be a %s, got %s", test.err, test.kind, class.Kind)
+		}
+		if class.Constraint != test.constraint {
+			t.Errorf("expected constraint %q for %v, got %q", test.constraint, test.err, class.Constraint)
+		}
+		if class.Column != test.column {
+			t.Errorf("expected column %q for %v, got %q", test.column, test.err, class.Column)
+		}
+	}
+}
+
+// The driver errors are classified without importing the drivers, which
+// would link and register them in every program using sqlx.
+func TestClassifyErrorImports(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			switch path {
+			case "github.com/lib/pq", "github.com/go-sql-driver/mysql", "github.com/mattn/go-sqlite3":
+				t.Errorf("%s imports the driver %s", file, path)
+			}
+		}
+	}
+}
+
+func TestConstraintViolations(t *testing.T) {
+	var schema = Schema{
+		create: `
+CREATE TABLE account (
+	email varchar(64) NOT NULL UNIQUE
+);`,
+		drop: `drop table account;`,
+	}
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		db.MustExec(db.Rebind("INSERT INTO account (email) VALUES (?)"), "a@b.c")
+		_, err := db.Exec(db.Rebind("INSERT INTO account (email) VALUES (?)"), "a@b.c")
+		if !IsUniqueViolation(err) {
+			t.Errorf("expected a unique violation, got %v", err)
+		}
+		_, err = db.Exec(db.Rebind("INSERT INTO account (email) VALUES (?)"), nil)
+		if !IsNotNullViolation(err) {
+			t.Errorf("expected a not null violation, got %v", err)
+		}
+		if db.DriverName() == "sqlite3" && ClassifyError(err).Column != "email" {
+			t.Errorf("expected the column to be email, got %#v", ClassifyError(err))
+		}
+	})
+}
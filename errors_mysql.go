@@ -0,0 +1,58 @@
+package sqlx
+
+import (
+    "reflect"
+    "regexp"
+    "strconv"
+)
+
+var (
+    reMySQLDuplicateKey = regexp.MustCompile("for key '(?:[^'.]*\\.)?([^']*)'")
+    reMySQLConstraint   = regexp.MustCompile("CONSTRAINT `([^`]*)`")
+    reMySQLForeignKey   = regexp.MustCompile("FOREIGN KEY \\(`([^`]*)`\\)")
+    reMySQLColumn       = regexp.MustCompile("(?i)column '([^']*)'")
+)
+
+func init() {
+    RegisterErrorClassifier(classifyMySQL)
+}
+
+// classifyMySQL classifies *mysql.MySQLError by its error number, and the
+// connection errors of the mysql driver.  The driver is not imported, the
+// error is recognized by its type and its fields are read by reflection.
+func classifyMySQL(err error) (ErrorClass, bool) {
+    // mysql.ErrInvalidConn is a plain error
+    if err.Error() == "invalid connection" {
+        return ErrorClass{Kind: ConnectionError}, true
+    }
+    v, ok := driverError(err, "github.com/go-sql-driver/mysql", "MySQLError")
+    if !ok {
+        return ErrorClass{}, false
+    }
+    number, message := v.FieldByName("Number"), v.FieldByName("Message")
+    if number.Kind() != reflect.Uint16 || message.Kind() != reflect.String {
+        return ErrorClass{}, false
+    }
+
+    msg := message.String()
+    class := ErrorClass{Code: strconv.FormatUint(number.Uint(), 10)}
+    switch number.Uint() {
+    case 1062, 1586: // ER_DUP_ENTRY, ER_DUP_ENTRY_WITH_KEY_NAME
+        class.Kind = UniqueViolation
+        class.Constraint = submatch(reMySQLDuplicateKey, msg)
+    case 1216, 1217, 1451, 1452: // ER_NO_REFERENCED_ROW, ER_ROW_IS_REFERENCED{,_2}
+        class.Kind = ForeignKeyViolation
+        class.Constraint = submatch(reMySQLConstraint, msg)
+        class.Column = submatch(reMySQLForeignKey, msg)
+    case 1048, 1364: // ER_BAD_NULL_ERROR, ER_NO_DEFAULT_FOR_FIELD
+        class.Kind = NotNullViolation
+        class.Column = submatch(reMySQLColumn, msg)
+    case 1213: // ER_LOCK_DEADLOCK
+        class.Kind = Deadlock
+    case 1205: // ER_LOCK_WAIT_TIMEOUT
+        class.Kind = LockTimeout
+    case 1040, 1053, 1152, 1158, 1159, 1160, 1161, 2002, 2003, 2006, 2013:
+        class.Kind = ConnectionError
+    }
+    return class, true
+}
@@ -0,0 +1,51 @@
+package sqlx
+
+func init() {
+    RegisterErrorClassifier(classifyPostgres)
+}
+
+// pqError is implemented by *pq.Error, whose Get returns the field of the
+// error with the given protocol code.  The driver is not imported so that
+// programs which do not use it do not link it.
+type pqError interface {
+    error
+    Get(k byte) string
+    Fatal() bool
+}
+
+// classifyPostgres classifies *pq.Error by its SQLSTATE code.
+func classifyPostgres(err error) (ErrorClass, bool) {
+    pe, ok := err.(pqError)
+    if !ok {
+        return ErrorClass{}, false
+    }
+
+    code := pe.Get('C')
+    class := ErrorClass{
+        Code:       code,
+        Constraint: pe.Get('n'),
+        Table:      pe.Get('t'),
+        Column:     pe.Get('c'),
+    }
+    switch code {
+    case "23505":
+        class.Kind = UniqueViolation
+    case "23503":
+        class.Kind = ForeignKeyViolation
+    case "23502":
+        class.Kind = NotNullViolation
+    case "40P01":
+        class.Kind = Deadlock
+    case "40001":
+        class.Kind = SerializationFailure
+    case "55P03":
+        class.Kind = LockTimeout
+    default:
+        // class 08 is connection exception, 57P01-57P03 are shutdowns
+        switch {
+        case len(code) == 5 && code[:2] == "08", code == "57P01", code == "57P02", code == "57P03":
+            class.Kind = ConnectionError
+        }
+    }
+    return class, true
+}
@@ -0,0 +1,75 @@
+package sqlx
+
+import (
+    "reflect"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// eg. "UNIQUE constraint failed: person.email" or "NOT NULL constraint failed: person.email"
+var reSqliteConstraintColumn = regexp.MustCompile(`constraint failed: ([^\s,]+)`)
+
+// sqlite3 result codes, see https://www.sqlite.org/rescode.html.  Extended
+// codes keep the primary code in their low byte.
+const (
+    sqliteBusy       = 5
+    sqliteLocked     = 6
+    sqliteCantOpen   = 14
+    sqliteConstraint = 19
+
+    sqliteBusySnapshot         = sqliteBusy | 2<<8
+    sqliteConstraintForeignKey = sqliteConstraint | 3<<8
+    sqliteConstraintNotNull    = sqliteConstraint | 5<<8
+    sqliteConstraintPrimaryKey = sqliteConstraint | 6<<8
+    sqliteConstraintUnique     = sqliteConstraint | 8<<8
+)
+
+func init() {
+    RegisterErrorClassifier(classifySqlite3)
+}
+
+// classifySqlite3 classifies sqlite3.Error by its extended result code.  The
+// driver, which needs cgo, is not imported;  the error is recognized by its
+// type and its codes are read by reflection.
+func classifySqlite3(err error) (ErrorClass, bool) {
+    v, ok := driverError(err, "github.com/mattn/go-sqlite3", "Error")
+    if !ok {
+        return ErrorClass{}, false
+    }
+    code, extended := v.FieldByName("Code"), v.FieldByName("ExtendedCode")
+    if code.Kind() != reflect.Int || extended.Kind() != reflect.Int {
+        return ErrorClass{}, false
+    }
+
+    class := ErrorClass{Code: strconv.FormatInt(extended.Int(), 10)}
+    switch extended.Int() {
+    case sqliteConstraintUnique, sqliteConstraintPrimaryKey:
+        class.Kind = UniqueViolation
+    case sqliteConstraintForeignKey:
+        class.Kind = ForeignKeyViolation
+    case sqliteConstraintNotNull:
+        class.Kind = NotNullViolation
+    }
+    switch {
+    case extended.Int() == sqliteBusySnapshot:
+        // a WAL read transaction can not be upgraded to a write transaction
+        // as the database changed since it started
+        class.Kind = SerializationFailure
+    case code.Int() == sqliteBusy, code.Int() == sqliteLocked:
+        class.Kind = LockTimeout
+    case code.Int() == sqliteCantOpen:
+        class.Kind = ConnectionError
+    }
+
+    // sqlite reports table.column for unique and not null violations;  for
+    // multi-column unique indexes only the first column is kept
+    if column := submatch(reSqliteConstraintColumn, err.Error()); column != "" {
+        if i := strings.IndexByte(column, '.'); i >= 0 {
+            class.Table, class.Column = column[:i], column[i+1:]
+        } else {
+            class.Column = column
+        }
+    }
+    return class, true
+}
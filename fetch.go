@@ -1,9 +1,13 @@
 package sqlx
 
 import (
+    "context"
     "database/sql"
     "github.com/tietang/sqlx/reflectx"
     "reflect"
+    "strings"
+    "sync"
+    "unsafe"
     "upper.io/db.v3"
 )
 
@@ -11,9 +15,247 @@ type hasConvertValues interface {
     ConvertValues(values []interface{}) []interface{}
 }
 
+// minSliceCap is the capacity given to an empty destination slice before the
+// first row is appended to it.
+const minSliceCap = 16
+
+// scanPlan maps every column of a result set onto a field of a struct type.
+// Plans are computed once per (mapper, type, columns) and shared between
+// queries, so they must never be mutated after construction.
+type scanPlan struct {
+    fields    []planField
+    groups    []nullGroup
+    afterScan bool
+}
+
+// planField describes the destination of a single column.  A nil index means
+// the column has no matching field and is discarded.  When direct is set the
+// field lives in the struct's own memory (no pointers on the path) and is
+// addressed by offset instead of walking the index with reflect.
+type planField struct {
+    index  []int
+    typ    reflect.Type
+    offset uintptr
+    direct bool
+}
+
+type planKey struct {
+    mapper  *reflectx.Mapper
+    typ     reflect.Type
+    columns string
+}
+
+// maxScanPlans bounds the plan cache, which would otherwise grow with every
+// distinct column list of dynamic queries and keep their mappers alive.
+const maxScanPlans = 1024
+
+var (
+    scanPlansMu sync.RWMutex
+    scanPlans   = make(map[planKey]*scanPlan)
+)
+
+// getScanPlan returns the cached plan for scanning columns into the struct
+// type t, computing it on first use.
+func getScanPlan(m *reflectx.Mapper, t reflect.Type, columns []string) (*scanPlan, error) {
+    key := planKey{mapper: m, typ: t, columns: strings.Join(columns, "\x00")}
+    scanPlansMu.RLock()
+    p, ok := scanPlans[key]
+    scanPlansMu.RUnlock()
+    if ok {
+        return p, nil
+    }
+
+    fieldMap := m.TypeMap(t).Names
+    plan := &scanPlan{fields: make([]planField, len(columns)), afterScan: hasAfterScan(t)}
+    traversals := make([][]int, len(columns))
+
+    for i, k := range columns {
+        fi, ok := fieldMap[k]
+        if !ok {
+            continue
+        }
+
+        // Check for deprecated jsonb tag.
+        if _, hasJSONBTag := fi.Options["jsonb"]; hasJSONBTag {
+            return nil, errDeprecatedJSONBTag
+        }
+        traversals[i] = fi.Index
+    }
+
+    plan.groups, traversals = nullGroups(m, t, traversals)
+
+    for i, index := range traversals {
+        if len(index) == 0 {
+            continue
+        }
+
+        f := planField{index: index, typ: fieldMap[columns[i]].Field.Type, direct: true}
+        st := t
+        for _, idx := range index {
+            if st.Kind() != reflect.Struct {
+                f.direct = false
+                break
+            }
+            sf := st.Field(idx)
+            f.offset += sf.Offset
+            st = sf.Type
+        }
+        plan.fields[i] = f
+    }
+
+    storeScanPlan(key, plan)
+    return plan, nil
+}
+
+// storeScanPlan caches plan under key.  When the cache is full an arbitrary
+// plan is evicted to make room;  it is simply recomputed if it is needed again.
+func storeScanPlan(key planKey, plan *scanPlan) {
+    scanPlansMu.Lock()
+    defer scanPlansMu.Unlock()
+    if _, ok := scanPlans[key]; !ok && len(scanPlans) >= maxScanPlans {
+        for k := range scanPlans {
+            delete(scanPlans, k)
+            break
+        }
+    }
+    scanPlans[key] = plan
+}
+
+// bind points values at the fields of item, which must be an addressable
+// struct of the plan's type.  Columns without a field of their own are pointed
+// at the matching element of unbound, so that no memory is allocated per row.
+func (p *scanPlan) bind(item reflect.Value, values, unbound []interface{}) {
+    base := unsafe.Pointer(item.UnsafeAddr())
+    for i, f := range p.fields {
+        switch {
+        case f.index == nil:
+            values[i] = unbound[i]
+        case f.direct:
+            values[i] = reflect.NewAt(f.typ, unsafe.Pointer(uintptr(base)+f.offset)).Interface()
+        default:
+            values[i] = reflectx.FieldByIndexes(item, f.index).Addr().Interface()
+        }
+    }
+}
+
+// rowScanner scans consecutive rows of a single result set into structs or
+// maps, reusing its buffers between rows.
+type rowScanner struct {
+    ctx     context.Context
+    rows    *sql.Rows
+    columns []string
+    plan    *scanPlan
+    values  []interface{}
+    // unbound are the scan destinations which do not depend on the row: the
+    // discarded columns and the temporaries of null groups of structs, and
+    // every column of maps, whose values are copied into the map.
+    unbound []interface{}
+}
+
+// newRowScanner prepares a scanner for the item type itemT, which may be a
+// struct, a pointer to a struct or a map.
+func newRowScanner(ctx context.Context, rows *sql.Rows, m *reflectx.Mapper, itemT reflect.Type, columns []string) (*rowScanner, error) {
+    objT := reflectx.Deref(itemT)
+
+    switch {
+    case objT.Kind() == reflect.Struct:
+    case itemT.Kind() == reflect.Map:
+        objT = itemT
+    default:
+        return nil, ErrExpectingMapOrStruct
+    }
+
+    s := &rowScanner{
+        ctx:     ctx,
+        rows:    rows,
+        columns: columns,
+        values:  make([]interface{}, len(columns)),
+    }
+
+    if objT.Kind() == reflect.Struct {
+        if m == nil {
+            m = mapper()
+        }
+        plan, err := getScanPlan(m, objT, columns)
+        if err != nil {
+            return nil, err
+        }
+        s.plan = plan
+        scratch := make([]interface{}, len(columns))
+        s.unbound = make([]interface{}, len(columns))
+        for i := range s.unbound {
+            s.unbound[i] = &scratch[i]
+        }
+        bindNullGroups(plan.groups, s.unbound)
+        return s, nil
+    }
+
+    s.unbound = make([]interface{}, len(columns))
+    elemT := objT.Elem()
+    for i := range s.unbound {
+        if elemT.Kind() == reflect.Interface {
+            s.unbound[i] = new(interface{})
+        } else {
+            s.unbound[i] = reflect.New(elemT).Interface()
+        }
+    }
+    copy(s.values, s.unbound)
+    return s, nil
+}
+
+// scanStruct scans the current row into item, an addressable struct.
+func (s *rowScanner) scanStruct(item reflect.Value) error {
+    s.plan.bind(item, s.values, s.unbound)
+    if err := s.rows.Scan(s.values...); err != nil {
+        return err
+    }
+    setNullGroups(item, s.plan.groups, s.values)
+    if s.plan.afterScan {
+        return afterScan(s.ctx, item)
+    }
+    return nil
+}
+
+// scanMap scans the current row into a newly made map of type mapT.
+func (s *rowScanner) scanMap(mapT reflect.Type) (reflect.Value, error) {
+    item := reflect.MakeMapWithSize(mapT, len(s.columns))
+
+    if err := s.rows.Scan(s.values...); err != nil {
+        return item, err
+    }
+
+    for i, column := range s.columns {
+        item.SetMapIndex(reflect.ValueOf(column), reflect.Indirect(reflect.ValueOf(s.values[i])))
+    }
+
+    return item, nil
+}
+
+// scanInto scans the current row into v, an addressable value of the item
+// type the scanner was made for.
+func (s *rowScanner) scanInto(v reflect.Value) error {
+    switch v.Kind() {
+    case reflect.Map:
+        item, err := s.scanMap(v.Type())
+        if err != nil {
+            return err
+        }
+        v.Set(item)
+        return nil
+    case reflect.Ptr:
+        item := reflect.New(v.Type().Elem())
+        if err := s.scanStruct(item.Elem()); err != nil {
+            return err
+        }
+        v.Set(item)
+        return nil
+    }
+    return s.scanStruct(v)
+}
+
 // fetchRow receives a *sql.Rows value and tries to map all the rows into a
 // single struct given by the pointer `dst`.
-func fetchRow(rows *sql.Rows, dst interface{}) error {
+func fetchRow(ctx context.Context, rows *sql.Rows, dst interface{}, m *reflectx.Mapper) error {
     var columns []string
     var err error
 
@@ -40,25 +282,20 @@ func fetchRow(rows *sql.Rows, dst interface{}) error {
         return db.ErrNoMoreRows
     }
 
-    itemT := itemV.Type()
-    item, err := fetchResult(rows, itemT, columns)
-
+    s, err := newRowScanner(ctx, rows, m, itemV.Type(), columns)
     if err != nil {
         return err
     }
 
-    if itemT.Kind() == reflect.Ptr {
-        itemV.Set(item)
-    } else {
-        itemV.Set(reflect.Indirect(item))
-    }
-
-    return nil
+    return s.scanInto(itemV)
 }
 
 // fetchRows receives a *sql.Rows value and tries to map all the rows into a
-// slice of structs given by the pointer `dst`.
-func fetchRows(rows *sql.Rows, dst interface{}) error {
+// slice of structs given by the pointer `dst`.  Rows are scanned in place
+// into the slice's backing array, which is grown geometrically; a slice
+// pre-sized with make([]T, 0, n) keeps its capacity as a hint and is not
+// reallocated until it holds n rows.
+func fetchRows(ctx context.Context, rows *sql.Rows, dst interface{}, m *reflectx.Mapper) error {
     var err error
     defer rows.Close()
 
@@ -73,111 +310,41 @@ func fetchRows(rows *sql.Rows, dst interface{}) error {
         return ErrExpectingSlicePointer
     }
 
-    if dstv.Kind() != reflect.Ptr || dstv.Elem().Kind() != reflect.Slice || dstv.IsNil() {
-        return ErrExpectingSliceMapStruct
-    }
-
     var columns []string
     if columns, err = rows.Columns(); err != nil {
         return err
     }
 
-    slicev := dstv.Elem()
-    itemT := slicev.Type().Elem()
-
     reset(dst)
 
-    for rows.Next() {
-        item, err := fetchResult(rows, itemT, columns)
-        if err != nil {
-            return err
-        }
-        if itemT.Kind() == reflect.Ptr {
-            slicev = reflect.Append(slicev, item)
-        } else {
-            slicev = reflect.Append(slicev, reflect.Indirect(item))
-        }
-    }
-
-    dstv.Elem().Set(slicev)
-
-    return rows.Err()
-}
-
-func fetchResult(rows *sql.Rows, itemT reflect.Type, columns []string) (reflect.Value, error) {
-    var item reflect.Value
-    var err error
-
-    objT := itemT
+    slicev := dstv.Elem()
+    itemT := slicev.Type().Elem()
 
-    switch objT.Kind() {
-    case reflect.Map:
-        item = reflect.MakeMap(objT)
-    case reflect.Struct:
-        item = reflect.New(objT)
-    case reflect.Ptr:
-        objT = itemT.Elem()
-        if objT.Kind() != reflect.Struct {
-            return item, ErrExpectingMapOrStruct
-        }
-        item = reflect.New(objT)
-    default:
-        return item, ErrExpectingMapOrStruct
+    s, err := newRowScanner(ctx, rows, m, itemT, columns)
+    if err != nil {
+        return err
     }
 
-    switch objT.Kind() {
-    case reflect.Struct:
-
-        values := make([]interface{}, len(columns))
-        typeMap := mapper().TypeMap(itemT)
-        fieldMap := typeMap.Names
-
-        for i, k := range columns {
-            fi, ok := fieldMap[k]
-            if !ok {
-                values[i] = new(interface{})
-                continue
-            }
-
-            // Check for deprecated jsonb tag.
-            if _, hasJSONBTag := fi.Options["jsonb"]; hasJSONBTag {
-                return item, errDeprecatedJSONBTag
-            }
-
-            f := reflectx.FieldByIndexes(item, fi.Index)
-            values[i] = f.Addr().Interface()
-
-        }
-
-        if err = rows.Scan(values...); err != nil {
-            return item, err
-        }
-    case reflect.Map:
-
-        columns, err := rows.Columns()
-        if err != nil {
-            return item, err
-        }
-
-        values := make([]interface{}, len(columns))
-        for i := range values {
-            if itemT.Elem().Kind() == reflect.Interface {
-                values[i] = new(interface{})
-            } else {
-                values[i] = reflect.New(itemT.Elem()).Interface()
+    n := 0
+    for rows.Next() {
+        if n == slicev.Cap() {
+            c := 2 * n
+            if c < minSliceCap {
+                c = minSliceCap
             }
+            grown := reflect.MakeSlice(slicev.Type(), n, c)
+            reflect.Copy(grown, slicev)
+            slicev.Set(grown)
         }
-
-        if err = rows.Scan(values...); err != nil {
-            return item, err
-        }
-
-        for i, column := range columns {
-            item.SetMapIndex(reflect.ValueOf(column), reflect.Indirect(reflect.ValueOf(values[i])))
+        slicev.SetLen(n + 1)
+        if err = s.scanInto(slicev.Index(n)); err != nil {
+            slicev.SetLen(n)
+            return err
         }
+        n++
     }
 
-    return item, nil
+    return rows.Err()
 }
 
 func reset(data interface{}) error {
@@ -0,0 +1,319 @@
+package sqlx
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fetchDriver is an in-memory driver whose queries return the number of rows
+// given as the query text, with the columns id, name, email, score and extra.
+// A comma separated list of counts yields several result sets; those after
+// the first have only the columns id and score.
+type fetchDriver struct{}
+
+type fetchConn struct{}
+
+type fetchStmt struct{ sets []int }
+
+type fetchRowsSource struct {
+	sets   []int
+	set, i int
+}
+
+func init() {
+	sql.Register("sqlxfetch", fetchDriver{})
+}
+
+func (fetchDriver) Open(string) (driver.Conn, error) { return fetchConn{}, nil }
+
+func (fetchConn) Prepare(query string) (driver.Stmt, error) {
+	var sets []int
+	for _, c := range strings.Split(query, ",") {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, n)
+	}
+	return fetchStmt{sets}, nil
+}
+
+func (fetchConn) Close() error              { return nil }
+func (fetchConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+func (fetchStmt) Close() error  { return nil }
+func (fetchStmt) NumInput() int { return -1 }
+
+func (fetchStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+
+func (s fetchStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fetchRowsSource{sets: s.sets}, nil
+}
+
+func (r *fetchRowsSource) Columns() []string {
+	if r.set > 0 {
+		return []string{"id", "score"}
+	}
+	return []string{"id", "name", "email", "score", "extra"}
+}
+
+func (*fetchRowsSource) Close() error { return nil }
+
+func (r *fetchRowsSource) HasNextResultSet() bool { return r.set+1 < len(r.sets) }
+
+func (r *fetchRowsSource) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.set++
+	r.i = 0
+	return nil
+}
+
+func (r *fetchRowsSource) Next(dest []driver.Value) error {
+	if r.i == r.sets[r.set] {
+		return io.EOF
+	}
+	r.i++
+	dest[0] = int64(r.i)
+	if r.set > 0 {
+		dest[1] = float64(r.i) / 2
+		return nil
+	}
+	dest[1] = "name"
+	dest[2] = "name@example.com"
+	dest[3] = float64(r.i) / 2
+	dest[4] = nil
+	return nil
+}
+
+type FetchContact struct {
+	Email string
+}
+
+type fetchUser struct {
+	ID    int64
+	Name  string
+	Score float64
+	*FetchContact
+}
+
+// fetchGroupUser scans email and extra into null groups through their "e"
+// prefix;  extra is always NULL.
+type fetchGroupUser struct {
+	ID      int64
+	Contact *struct {
+		Mail string
+	} `db:"contact,prefix=e"`
+	Extra *struct {
+		Xtra *string
+	} `db:"extra,prefix=e"`
+}
+
+type fetchFlatUser struct {
+	ID    int64
+	Name  string
+	Email string
+	Score float64
+}
+
+func TestFetchRows(t *testing.T) {
+	db, err := Open("sqlxfetch", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var users []fetchUser
+	if err = db.Select(&users, "40"); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 40 {
+		t.Fatalf("expected 40 users, got %d", len(users))
+	}
+	for i, u := range users {
+		if u.ID != int64(i+1) || u.Name != "name" || u.Score != float64(i+1)/2 {
+			t.Errorf("unexpected user at %d: %#v", i, u)
+		}
+		if u.FetchContact == nil || u.Email != "name@example.com" {
+			t.Errorf("expected embedded pointer to be allocated and set at %d", i)
+		}
+	}
+	if users[0].FetchContact == users[1].FetchContact {
+		t.Error("expected every row to get its own embedded struct")
+	}
+
+	// a pre-sized slice keeps its backing array
+	flat := make([]*fetchFlatUser, 0, 8)
+	first := flat[:1]
+	if err = db.Select(&flat, "8"); err != nil {
+		t.Fatal(err)
+	}
+	if len(flat) != 8 || cap(flat) != 8 {
+		t.Errorf("expected len and cap 8, got %d and %d", len(flat), cap(flat))
+	}
+	if first[0] != nil {
+		t.Error("expected the result not to alias the previous slice")
+	}
+	if flat[7].ID != 8 || flat[7].Email != "name@example.com" {
+		t.Errorf("unexpected last user: %#v", flat[7])
+	}
+
+	// null group temporaries are reused between rows
+	var grouped []fetchGroupUser
+	if err = db.Select(&grouped, "3"); err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range grouped {
+		if g.ID != int64(i+1) || g.Contact == nil || g.Contact.Mail != "name@example.com" || g.Extra != nil {
+			t.Errorf("unexpected grouped user at %d: %#v", i, g)
+		}
+	}
+	if grouped[0].Contact == grouped[1].Contact {
+		t.Error("expected every row to get its own group struct")
+	}
+
+	var u fetchFlatUser
+	if err = db.Get(&u, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 1 {
+		t.Errorf("expected the first row, got %#v", u)
+	}
+}
+
+func TestScanPlanCacheBound(t *testing.T) {
+	m := mapper()
+	typ := reflect.TypeOf(fetchFlatUser{})
+	for i := 0; i < maxScanPlans+10; i++ {
+		if _, err := getScanPlan(m, typ, []string{"id", "c" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scanPlansMu.RLock()
+	n := len(scanPlans)
+	scanPlansMu.RUnlock()
+	if n > maxScanPlans {
+		t.Errorf("expected at most %d cached plans, got %d", maxScanPlans, n)
+	}
+
+	p, err := getScanPlan(m, typ, []string{"id", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, _ := getScanPlan(m, typ, []string{"id", "name"}); q != p {
+		t.Error("expected the plan to be cached")
+	}
+}
+
+func benchmarkFetchRows(b *testing.B, n int, dest func() interface{}) {
+	db, err := Open("sqlxfetch", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	query := strconv.Itoa(n)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.Queryx(query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = scanAll(rows, dest(), false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFetchRows1k(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		var users []fetchFlatUser
+		return &users
+	})
+}
+
+func BenchmarkFetchRows1kPresized(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		users := make([]fetchFlatUser, 0, 1000)
+		return &users
+	})
+}
+
+func BenchmarkFetchRows1kPtr(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		var users []*fetchFlatUser
+		return &users
+	})
+}
+
+func BenchmarkFetchRows1kEmbedded(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		var users []fetchUser
+		return &users
+	})
+}
+
+func BenchmarkFetchRows1kNullGroups(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		var users []fetchGroupUser
+		return &users
+	})
+}
+
+// BenchmarkFetchRows1kUncached drops the scan plans before every query, to
+// show what caching them saves.
+func BenchmarkFetchRows1kUncached(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		scanPlansMu.Lock()
+		scanPlans = make(map[planKey]*scanPlan)
+		scanPlansMu.Unlock()
+		var users []fetchFlatUser
+		return &users
+	})
+}
+
+// BenchmarkStructScan1k scans the rows of BenchmarkFetchRows1k one at a time
+// with Rows.StructScan, which walks the field index of every column on every
+// row, for comparison with the offsets of the scan plans.
+func BenchmarkStructScan1k(b *testing.B) {
+	db, err := Open("sqlxfetch", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	db = db.Unsafe()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := db.Queryx("1000")
+		if err != nil {
+			b.Fatal(err)
+		}
+		var users []fetchFlatUser
+		for rows.Next() {
+			var u fetchFlatUser
+			if err = rows.StructScan(&u); err != nil {
+				b.Fatal(err)
+			}
+			users = append(users, u)
+		}
+		if err = rows.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFetchRows1kMap(b *testing.B) {
+	benchmarkFetchRows(b, 1000, func() interface{} {
+		var users []map[string]interface{}
+		return &users
+	})
+}
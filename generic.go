@@ -0,0 +1,90 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlx
+
+import (
+    "context"
+    "database/sql"
+    "reflect"
+)
+
+// SelectT executes a query using the provided QueryerContext and returns every
+// row scanned into a T.  If T (or what it points to) is scannable, the result
+// set must have only one column.  Otherwise, StructScan is used.  The rows are
+// closed automatically.
+// Any placeholder parameters are replaced with supplied args.
+func SelectT[T any](ctx context.Context, q QueryerContext, query string, args ...interface{}) ([]T, error) {
+    rows, err := q.QueryxContext(ctx, query, args...)
+    if err != nil {
+        return nil, queryError("select", q, query, args, err)
+    }
+    defer rows.Close()
+
+    var result []T
+    s := newTypedScanner[T]()
+    for rows.Next() {
+        v, err := s.scan(rows)
+        if err != nil {
+            return nil, queryError("select", q, query, args, err)
+        }
+        result = append(result, v)
+    }
+    return result, queryError("select", q, query, args, rows.Err())
+}
+
+// GetT executes a query using the provided QueryerContext and returns the first
+// row scanned into a T.  GetT returns sql.ErrNoRows if the result set is empty.
+// Any placeholder parameters are replaced with supplied args.
+func GetT[T any](ctx context.Context, q QueryerContext, query string, args ...interface{}) (T, error) {
+    var zero T
+    rows, err := q.QueryxContext(ctx, query, args...)
+    if err != nil {
+        return zero, queryError("get", q, query, args, err)
+    }
+    defer rows.Close()
+
+    if !rows.Next() {
+        if err := rows.Err(); err != nil {
+            return zero, queryError("get", q, query, args, err)
+        }
+        return zero, sql.ErrNoRows
+    }
+    v, err := newTypedScanner[T]().scan(rows)
+    if err != nil {
+        return zero, queryError("get", q, query, args, err)
+    }
+    return v, nil
+}
+
+// typedScanner scans rows into values of type T, which may be a pointer.
+type typedScanner[T any] struct {
+    isPtr     bool
+    base      reflect.Type
+    scannable bool
+}
+
+func newTypedScanner[T any]() typedScanner[T] {
+    t := reflect.TypeOf((*T)(nil)).Elem()
+    base := t
+    if t.Kind() == reflect.Ptr {
+        base = t.Elem()
+    }
+    return typedScanner[T]{isPtr: base != t, base: base, scannable: isScannable(base)}
+}
+
+// scan scans the current row of rows into a new T.
+func (s typedScanner[T]) scan(rows *Rows) (T, error) {
+    var v T
+    dest := interface{}(&v)
+    if s.isPtr {
+        // T is a pointer;  allocate what it points to and scan into that
+        p := reflect.New(s.base)
+        reflect.ValueOf(&v).Elem().Set(p)
+        dest = p.Interface()
+    }
+    if s.scannable {
+        return v, rows.Scan(dest)
+    }
+    return v, rows.StructScan(dest)
+}
@@ -0,0 +1,49 @@
+//go:build go1.23
+// +build go1.23
+
+package sqlx
+
+import (
+    "context"
+    "iter"
+)
+
+// RowsOf returns an iterator over the rows of a query, each scanned into a T
+// as by SelectT.  The query is executed when iteration starts and its rows are
+// closed when iteration stops, so breaking out of the loop early is safe.  An
+// error ends the iteration, and is yielded along with the zero T:
+//
+//    for p, err := range sqlx.RowsOf[Person](ctx, db, "SELECT * FROM person") {
+//        if err != nil {
+//            return err
+//        }
+//        ...
+//    }
+//
+// Any placeholder parameters are replaced with supplied args.
+func RowsOf[T any](ctx context.Context, q QueryerContext, query string, args ...interface{}) iter.Seq2[T, error] {
+    return func(yield func(T, error) bool) {
+        var zero T
+        rows, err := q.QueryxContext(ctx, query, args...)
+        if err != nil {
+            yield(zero, queryError("query", q, query, args, err))
+            return
+        }
+        defer rows.Close()
+
+        s := newTypedScanner[T]()
+        for rows.Next() {
+            v, err := s.scan(rows)
+            if err != nil {
+                yield(zero, queryError("query", q, query, args, err))
+                return
+            }
+            if !yield(v, nil) {
+                return
+            }
+        }
+        if err := rows.Err(); err != nil {
+            yield(zero, queryError("query", q, query, args, err))
+        }
+    }
+}
@@ -0,0 +1,77 @@
+//go:build go1.23
+// +build go1.23
+
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestGenericQueries(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		loadDefaultFixture(db, t)
+		ctx := context.Background()
+
+		people, err := SelectT[Person](ctx, db, "SELECT * FROM person ORDER BY first_name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(people) != 2 || people[0].FirstName != "Jason" || people[1].FirstName != "John" {
+			t.Errorf("unexpected people: %#v", people)
+		}
+
+		ptrs, err := SelectT[*Place](ctx, db, "SELECT * FROM place ORDER BY telcode")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ptrs) != 3 || ptrs[0].TelCode != 1 {
+			t.Errorf("unexpected places: %#v", ptrs)
+		}
+
+		names, err := SelectT[string](ctx, db, "SELECT first_name FROM person ORDER BY first_name")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 2 || names[0] != "Jason" {
+			t.Errorf("unexpected names: %#v", names)
+		}
+
+		p, err := GetT[Person](ctx, db, db.Rebind("SELECT * FROM person WHERE first_name = ?"), "John")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.LastName != "Doe" {
+			t.Errorf("expected Doe, got %s", p.LastName)
+		}
+
+		_, err = GetT[Person](ctx, db, db.Rebind("SELECT * FROM person WHERE first_name = ?"), "nobody")
+		if err != sql.ErrNoRows {
+			t.Errorf("expected sql.ErrNoRows, got %v", err)
+		}
+
+		var count int
+		for place, err := range RowsOf[Place](ctx, db, "SELECT * FROM place ORDER BY telcode") {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count == 0 && place.Country != "United States" {
+				t.Errorf("expected United States first, got %s", place.Country)
+			}
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		if count != 2 {
+			t.Errorf("expected to stop after 2 places, got %d", count)
+		}
+
+		for _, err := range RowsOf[Place](ctx, db, "SELECT * FROM nonexistent") {
+			if err == nil {
+				t.Error("expected an error from a bad query")
+			}
+		}
+	})
+}
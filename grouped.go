@@ -0,0 +1,281 @@
+package sqlx
+
+import (
+    "errors"
+    "fmt"
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+    "strings"
+)
+
+// SelectGrouped executes a query using the provided Queryer and scans the
+// rows of a one-to-many join into dest, a pointer to a slice of structs.
+// Rows are grouped into parent structs by the fields tagged with the `key`
+// option, and the child columns of each row are appended to the parent's
+// slice fields tagged with the `many` option:
+//
+//     type Order struct {
+//         ID    int64  `db:"id,key"`
+//         Items []Item `db:"items,many"`
+//     }
+//
+//     SelectGrouped(db, &orders, `SELECT o.*, i.id "items.id", i.sku "items.sku"
+//         FROM orders o LEFT JOIN order_items i ON i.order_id = o.id ORDER BY o.id`)
+//
+// Child columns are named after the slice field, as with nested structs, or
+// start with its `prefix` option.  Children may declare keys and many fields
+// of their own; children with keys are deduplicated within their parent.  A
+// child whose columns are all NULL, as on the outer side of a LEFT JOIN, is not
+// appended.  Parents and children keep the order of their first row.  Columns
+// which match no field are an error, unless q is unsafe.  Map and the write
+// helpers leave many fields out, so a parent is written without its children.
+func SelectGrouped(q Queryer, dest interface{}, query string, args ...interface{}) error {
+    rows, err := q.Queryx(query, args...)
+    if err != nil {
+        return queryError("select", q, query, args, err)
+    }
+    // if something happens here, we want to make sure the rows are Closed
+    defer rows.Close()
+    return queryError("select", q, query, args, scanGrouped(rows, dest))
+}
+
+// groupPlan maps the columns of a result set onto a struct type and its
+// slice fields tagged with the `many` option.
+type groupPlan struct {
+    typ    reflect.Type // element type, a struct or a pointer to one
+    base   reflect.Type
+    fields []groupColumn
+    keys   []int // positions in fields of the key columns
+    many   []groupMany
+}
+
+type groupColumn struct {
+    pos   int
+    index []int
+    typ   reflect.Type
+}
+
+type groupMany struct {
+    index []int
+    plan  *groupPlan
+}
+
+// groupNode is a struct already added to a slice, with the keyed children of
+// each of its many fields.
+type groupNode struct {
+    index    int
+    children []map[interface{}]*groupNode
+}
+
+func newGroupPlan(m *reflectx.Mapper, t reflect.Type, columns []string, positions []int) (*groupPlan, error) {
+    base := reflectx.Deref(t)
+    if base.Kind() != reflect.Struct || isScannable(base) {
+        return nil, fmt.Errorf("expected a struct to group rows into, got %s", t)
+    }
+
+    g := &groupPlan{typ: t, base: base}
+    tm := m.TypeMap(base)
+
+    type many struct {
+        fi        *reflectx.FieldInfo
+        prefix    string
+        columns   []string
+        positions []int
+    }
+    var manies []*many
+    for _, fi := range tm.Index {
+        if _, ok := fi.Options["many"]; !ok {
+            continue
+        }
+        if fi.Field.Type.Kind() != reflect.Slice {
+            return nil, fmt.Errorf("many field %s of %s is not a slice", fi.Field.Name, base)
+        }
+        prefix, ok := fi.Options["prefix"]
+        if !ok {
+            prefix = fi.Path + "."
+        }
+        manies = append(manies, &many{fi: fi, prefix: prefix})
+    }
+
+    for i, name := range columns {
+        if fi, ok := tm.Names[name]; ok && fi.Field.Type.Kind() != reflect.Slice {
+            if _, isKey := fi.Options["key"]; isKey {
+                g.keys = append(g.keys, len(g.fields))
+            }
+            g.fields = append(g.fields, groupColumn{pos: positions[i], index: fi.Index, typ: fi.Field.Type})
+            continue
+        }
+        for _, mf := range manies {
+            if strings.HasPrefix(name, mf.prefix) {
+                mf.columns = append(mf.columns, name[len(mf.prefix):])
+                mf.positions = append(mf.positions, positions[i])
+                break
+            }
+        }
+    }
+
+    for _, mf := range manies {
+        plan, err := newGroupPlan(m, mf.fi.Field.Type.Elem(), mf.columns, mf.positions)
+        if err != nil {
+            return nil, err
+        }
+        g.many = append(g.many, groupMany{index: mf.fi.Index, plan: plan})
+    }
+
+    return g, nil
+}
+
+// mark sets used for the positions of the columns the plan scans.
+func (g *groupPlan) mark(used []bool) {
+    for _, f := range g.fields {
+        used[f.pos] = true
+    }
+    for _, mf := range g.many {
+        mf.plan.mark(used)
+    }
+}
+
+// bind points values at temporaries for the columns of the plan.
+func (g *groupPlan) bind(values []interface{}) {
+    for _, f := range g.fields {
+        values[f.pos] = reflect.New(reflect.PtrTo(f.typ)).Interface()
+    }
+    for _, mf := range g.many {
+        mf.plan.bind(values)
+    }
+}
+
+// null reports whether every column of the plan's own fields is NULL.
+func (g *groupPlan) null(values []interface{}) bool {
+    for _, f := range g.fields {
+        if !reflect.ValueOf(values[f.pos]).Elem().IsNil() {
+            return false
+        }
+    }
+    return true
+}
+
+// key returns the map key for the row's key columns.
+func (g *groupPlan) key(values []interface{}) interface{} {
+    if len(g.keys) == 1 {
+        return keyValue(values[g.fields[g.keys[0]].pos])
+    }
+    var key strings.Builder
+    for _, k := range g.keys {
+        fmt.Fprintf(&key, "%v\x00", keyValue(values[g.fields[k].pos]))
+    }
+    return key.String()
+}
+
+func keyValue(v interface{}) interface{} {
+    p := reflect.ValueOf(v).Elem()
+    if p.IsNil() {
+        return nil
+    }
+    v = p.Elem().Interface()
+    if b, ok := v.([]byte); ok {
+        return string(b)
+    }
+    return v
+}
+
+// add appends the struct in the current row to the slice, unless a struct
+// with the same key was added before, and then adds its children.
+func (g *groupPlan) add(slice reflect.Value, nodes map[interface{}]*groupNode, values []interface{}) {
+    if g.null(values) {
+        return
+    }
+
+    var key interface{}
+    var node *groupNode
+    if len(g.keys) > 0 {
+        key = g.key(values)
+        node = nodes[key]
+    }
+
+    if node == nil {
+        item := reflect.New(g.base)
+        for _, f := range g.fields {
+            if src := reflect.ValueOf(values[f.pos]).Elem(); !src.IsNil() {
+                reflectx.FieldByIndexes(item, f.index).Set(src.Elem())
+            }
+        }
+        if g.typ.Kind() == reflect.Ptr {
+            slice.Set(reflect.Append(slice, item))
+        } else {
+            slice.Set(reflect.Append(slice, item.Elem()))
+        }
+
+        node = &groupNode{index: slice.Len() - 1, children: make([]map[interface{}]*groupNode, len(g.many))}
+        for i := range node.children {
+            node.children[i] = map[interface{}]*groupNode{}
+        }
+        if len(g.keys) > 0 {
+            nodes[key] = node
+        }
+    }
+
+    item := reflect.Indirect(slice.Index(node.index))
+    for i, mf := range g.many {
+        mf.plan.add(reflectx.FieldByIndexes(item, mf.index), node.children[i], values)
+    }
+}
+
+// scanGrouped scans all rows into dest, grouping them as SelectGrouped does.
+func scanGrouped(rows *Rows, dest interface{}) error {
+    defer rows.Close()
+
+    value := reflect.ValueOf(dest)
+    if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Slice {
+        return ErrExpectingSlicePointer
+    }
+
+    columns, err := rows.Columns()
+    if err != nil {
+        return err
+    }
+    positions := make([]int, len(columns))
+    for i := range positions {
+        positions[i] = i
+    }
+
+    m := rows.Mapper
+    if m == nil {
+        m = mapper()
+    }
+    slice := value.Elem()
+    g, err := newGroupPlan(m, slice.Type().Elem(), columns, positions)
+    if err != nil {
+        return err
+    }
+    if len(g.keys) == 0 {
+        return errors.New("SelectGrouped requires a field tagged with the key option in " + g.base.String())
+    }
+    // if we are not unsafe and are missing fields, return an error
+    if !rows.unsafe {
+        used := make([]bool, len(columns))
+        g.mark(used)
+        for i, ok := range used {
+            if !ok {
+                return fmt.Errorf("missing destination name %s in %T", columns[i], dest)
+            }
+        }
+    }
+
+    reset(dest)
+
+    values := make([]interface{}, len(columns))
+    nodes := map[interface{}]*groupNode{}
+    for rows.Next() {
+        for i := range values {
+            values[i] = new(interface{})
+        }
+        g.bind(values)
+        if err = rows.Scan(values...); err != nil {
+            return err
+        }
+        g.add(slice, nodes, values)
+    }
+
+    return rows.Err()
+}
@@ -0,0 +1,77 @@
+package sqlx
+
+import (
+    "context"
+    "reflect"
+)
+
+// BeforeInserter is implemented by structs that prepare themselves for being
+// inserted, for example by normalizing fields.  BeforeInsert is called by Map
+// with MapInsert, and thus by DB.Insert, before the struct is read.  An error
+// aborts the write and is returned unchanged.
+type BeforeInserter interface {
+    BeforeInsert(ctx context.Context) error
+}
+
+// BeforeUpdater is implemented by structs that prepare themselves for being
+// updated.  BeforeUpdate is called by Map with MapUpdate.  An error aborts the
+// write and is returned unchanged.
+type BeforeUpdater interface {
+    BeforeUpdate(ctx context.Context) error
+}
+
+// Validator is implemented by structs that check themselves before being
+// written.  Validate is called by Map with MapInsert or MapUpdate, after the
+// Before hook, and an error aborts the write.
+type Validator interface {
+    Validate() error
+}
+
+// AfterScanner is implemented by structs that want to be notified once a row
+// has been scanned into them, for example to derive fields.  AfterScan is
+// called by Select, Get, Rows.StructScan and the functions built on them, with
+// the context of the query if it had one.  An error aborts the scan.
+type AfterScanner interface {
+    AfterScan(ctx context.Context) error
+}
+
+var afterScannerType = reflect.TypeOf((*AfterScanner)(nil)).Elem()
+
+// hasAfterScan reports whether pointers to the struct type t implement
+// AfterScanner.
+func hasAfterScan(t reflect.Type) bool {
+    return reflect.PtrTo(t).Implements(afterScannerType)
+}
+
+// afterScan calls the AfterScan hook of the addressable struct v.
+func afterScan(ctx context.Context, v reflect.Value) error {
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    return v.Addr().Interface().(AfterScanner).AfterScan(ctx)
+}
+
+// beforeWrite calls the hooks of item, a pointer to a struct, for a write of
+// the kind op.
+func beforeWrite(ctx context.Context, item interface{}, op MapOp) error {
+    switch op {
+    case MapInsert:
+        if h, ok := item.(BeforeInserter); ok {
+            if err := h.BeforeInsert(ctx); err != nil {
+                return err
+            }
+        }
+    case MapUpdate:
+        if h, ok := item.(BeforeUpdater); ok {
+            if err := h.BeforeUpdate(ctx); err != nil {
+                return err
+            }
+        }
+    default:
+        return nil
+    }
+    if v, ok := item.(Validator); ok {
+        return v.Validate()
+    }
+    return nil
+}
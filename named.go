@@ -10,13 +10,16 @@ package sqlx
 //
 //  * compileNamedQuery - rebind a named query, returning a query and list of names
 //  * bindArgs, bindMapArgs, bindAnyArgs - given a list of names, return an arglist
+//  * expandValues - repeat the VALUES tuple of an insert for batch execution
 //
 import (
     "database/sql"
     "errors"
     "fmt"
     "reflect"
+    "regexp"
     "strconv"
+    "strings"
     "unicode"
 
     "github.com/tietang/sqlx/reflectx"
@@ -40,9 +43,10 @@ func (n *NamedStmt) Close() error {
 func (n *NamedStmt) Exec(arg interface{}) (sql.Result, error) {
     args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
     if err != nil {
-        return *new(sql.Result), err
+        return *new(sql.Result), queryError("exec", n.Stmt, n.QueryString, nil, err)
     }
-    return n.Stmt.Exec(args...)
+    res, err := n.Stmt.Exec(args...)
+    return res, queryError("exec", n.Stmt, n.QueryString, args, err)
 }
 
 // Query executes a named statement using the struct argument, returning rows.
@@ -50,9 +54,10 @@ func (n *NamedStmt) Exec(arg interface{}) (sql.Result, error) {
 func (n *NamedStmt) Query(arg interface{}) (*sql.Rows, error) {
     args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
     if err != nil {
-        return nil, err
+        return nil, queryError("query", n.Stmt, n.QueryString, nil, err)
     }
-    return n.Stmt.Query(args...)
+    rows, err := n.Stmt.Query(args...)
+    return rows, queryError("query", n.Stmt, n.QueryString, args, err)
 }
 
 // QueryRow executes a named statement against the database.  Because sqlx cannot
@@ -62,7 +67,7 @@ func (n *NamedStmt) Query(arg interface{}) (*sql.Rows, error) {
 func (n *NamedStmt) QueryRow(arg interface{}) *Row {
     args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
     if err != nil {
-        return &Row{err: err}
+        return &Row{err: queryError("query", n.Stmt, n.QueryString, nil, err)}
     }
     return n.Stmt.QueryRowx(args...)
 }
@@ -297,6 +302,12 @@ func compileNamedQuery(qs []byte, bindType int) (query string, names []string, e
                     rebound = append(rebound, byte(b))
                 }
                 currentVar++
+            case COLON:
+                rebound = append(rebound, ':')
+                for _, b := range strconv.Itoa(currentVar) {
+                    rebound = append(rebound, byte(b))
+                }
+                currentVar++
             }
             // add this byte to string unless it was not part of the name
             if i != last {
@@ -339,18 +350,409 @@ func bindNamedMapper(bindType int, query string, arg interface{}, m *reflectx.Ma
 func NamedQuery(e Ext, query string, arg interface{}) (*Rows, error) {
     q, args, err := bindNamedMapper(BindType(e.DriverName()), query, arg, mapperFor(e))
     if err != nil {
-        return nil, err
+        return nil, queryError("named query", e, query, nil, err)
     }
-    return e.Queryx(q, args...)
+    rows, err := e.Queryx(q, args...)
+    return rows, queryError("named query", e, q, args, err)
 }
 
 // NamedExec uses BindStruct to get a query executable by the driver and
 // then runs Exec on the result.  Returns an error from the binding
 // or the query excution itself.
+//
+// If arg is a slice or array of structs or maps, the query is executed for
+// every element.  Queries with a VALUES clause are expanded into multi-row
+// inserts, split into as many statements as the driver's parameter limit
+// requires;  other queries are run through a NamedStmt.  If e is a *DB, the
+// statements are run inside a transaction, so a failing statement leaves no
+// element written.  Other Ext run them as they are.
 func NamedExec(e Ext, query string, arg interface{}) (sql.Result, error) {
+    if batch, ok := batchArg(arg); ok {
+        res, err := namedExecBatch(e, query, batch)
+        return res, queryError("named exec", e, query, nil, err)
+    }
     q, args, err := bindNamedMapper(BindType(e.DriverName()), query, arg, mapperFor(e))
+    if err != nil {
+        return nil, queryError("named exec", e, query, nil, err)
+    }
+    res, err := e.Exec(q, args...)
+    return res, queryError("named exec", e, q, args, err)
+}
+
+// -- Batch execution of Named Queries
+
+// Find the end of the column list and the start of the values tuple of an
+// insert statement, eg. `) VALUES (`.
+var valuesClause = regexp.MustCompile(`(?i)\)\s*VALUES\s*\(`)
+
+// batchArg returns the indirected value of arg if it is a slice or array of
+// structs or maps which NamedExec should execute as a batch.
+func batchArg(arg interface{}) (reflect.Value, bool) {
+    v := reflect.ValueOf(arg)
+    for v.Kind() == reflect.Ptr && !v.IsNil() {
+        v = v.Elem()
+    }
+    if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+        return v, false
+    }
+    switch reflectx.Deref(v.Type().Elem()).Kind() {
+    case reflect.Struct, reflect.Map:
+        return v, true
+    }
+    return v, false
+}
+
+// maxBindParams returns the number of bindvars a single statement may use
+// for a given driverName.
+func maxBindParams(driverName string) int {
+    switch driverName {
+    case "sqlite3":
+        return 999
+    case "sqlserver":
+        return 2100
+    case "mysql", "postgres", "pgx", "pq-timeouts", "cloudsqlpostgres":
+        return 65535
+    }
+    return 999
+}
+
+// expandValues repeats the VALUES tuple of an insert query compiled with the
+// QUESTION bindtype n times.  It returns false if the query has no VALUES
+// clause that can be repeated.
+func expandValues(query string, n int) (string, bool) {
+    start, end, ok := valuesTuple(query)
+    if !ok {
+        return query, false
+    }
+
+    tuple := query[start:end]
+    buf := make([]byte, 0, len(query)+(len(tuple)+2)*(n-1))
+    buf = append(buf, query[:end]...)
+    for i := 1; i < n; i++ {
+        buf = append(buf, ", "...)
+        buf = append(buf, tuple...)
+    }
+    buf = append(buf, query[end:]...)
+    return string(buf), true
+}
+
+// valuesTuple returns the span of the parenthesized VALUES tuple of an insert
+// query.
+func valuesTuple(query string) (start, end int, ok bool) {
+    loc := valuesClause.FindStringIndex(query)
+    if loc == nil {
+        return 0, 0, false
+    }
+    // loc[1] is just past the opening paren of the tuple;  find its match
+    start, end, depth := loc[1]-1, -1, 0
+    for i := start; i < len(query) && end < 0; i++ {
+        switch query[i] {
+        case '(':
+            depth++
+        case ')':
+            depth--
+            if depth == 0 {
+                end = i + 1
+            }
+        }
+    }
+    return start, end, end >= 0
+}
+
+// bindBatch binds the elements [i, j) of the batch to the list of names.
+func bindBatch(names []string, batch reflect.Value, i, j int, m *reflectx.Mapper) ([]interface{}, error) {
+    arglist := make([]interface{}, 0, len(names)*(j-i))
+    for ; i < j; i++ {
+        args, err := bindAnyArgs(names, batch.Index(i).Interface(), m)
+        if err != nil {
+            return nil, err
+        }
+        arglist = append(arglist, args...)
+    }
+    return arglist, nil
+}
+
+// batchInsert is a multi-row insert split into statements which stay under the
+// bindvar limit of the driver.
+type batchInsert struct {
+    names    []string
+    tuple    string // the query for a single row, with QUESTION bindvars
+    perStmt  int    // rows per statement
+    bindType int
+}
+
+// newBatchInsert compiles a named insert query for batch execution.  It returns
+// false if the query cannot be expanded into a multi-row insert.
+func newBatchInsert(query, driverName string) (*batchInsert, bool, error) {
+    bindType := BindType(driverName)
+    if bindType == NAMED {
+        // names can't be repeated within one statement
+        return nil, false, nil
+    }
+    q, names, err := compileNamedQuery([]byte(query), QUESTION)
+    if err != nil {
+        return nil, false, err
+    }
+    start, end, ok := valuesTuple(q)
+    if len(names) == 0 || !ok {
+        return nil, false, nil
+    }
+    // names outside the tuple, eg. in an ON CONFLICT clause, would have to
+    // be bound once rather than once per row;  run those queries per row
+    if strings.Count(q[:start], "?")+strings.Count(q[end:], "?") > 0 {
+        return nil, false, nil
+    }
+    perStmt := maxBindParams(driverName) / len(names)
+    if perStmt < 1 {
+        perStmt = 1
+    }
+    return &batchInsert{names: names, tuple: q, perStmt: perStmt, bindType: bindType}, true, nil
+}
+
+// chunk returns the query and arguments for inserting the rows [i, j).
+func (b *batchInsert) chunk(batch reflect.Value, i, j int, m *reflectx.Mapper) (string, []interface{}, error) {
+    q, ok := expandValues(b.tuple, j-i)
+    if !ok {
+        return "", nil, errors.New("could not expand VALUES clause of " + b.tuple)
+    }
+    args, err := bindBatch(b.names, batch, i, j, m)
+    if err != nil {
+        return "", nil, err
+    }
+    return Rebind(b.bindType, q), args, nil
+}
+
+// namedExecBatch executes query for every element of the batch.  If e is a
+// *DB, the statements are run within a transaction.
+func namedExecBatch(e Ext, query string, batch reflect.Value) (sql.Result, error) {
+    if batch.Len() == 0 {
+        return nil, errors.New("empty slice passed to NamedExec")
+    }
+    if db, ok := e.(*DB); ok {
+        tx, err := db.Beginx()
+        if err != nil {
+            return nil, err
+        }
+        res, err := namedExecBatch(tx, query, batch)
+        if err != nil {
+            tx.Rollback()
+            return nil, err
+        }
+        return res, tx.Commit()
+    }
+    m := mapperFor(e)
+    if cols, ok := newInsertColumns(query, batch.Type().Elem(), m); ok {
+        return cols.exec(e, batch, m)
+    }
+    return namedExecRows(e, query, batch, m)
+}
+
+// namedExecRows executes query for every element of the batch, as multi-row
+// inserts if possible.
+func namedExecRows(e Ext, query string, batch reflect.Value, m *reflectx.Mapper) (sql.Result, error) {
+    ins, ok, err := newBatchInsert(query, e.DriverName())
     if err != nil {
         return nil, err
     }
-    return e.Exec(q, args...)
+    if !ok {
+        return namedExecEach(e, query, batch)
+    }
+
+    var res batchResult
+    for i := 0; i < batch.Len(); i += ins.perStmt {
+        j := i + ins.perStmt
+        if j > batch.Len() {
+            j = batch.Len()
+        }
+        q, args, err := ins.chunk(batch, i, j, m)
+        if err != nil {
+            return nil, err
+        }
+        r, err := e.Exec(q, args...)
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, r)
+    }
+    return res, nil
+}
+
+// Match a named insert value which is a single named parameter.
+var namedParam = regexp.MustCompile(`^:[\p{L}\p{N}_.]+$`)
+
+// insertColumns is a named insert of structs with columns which are not always
+// written, see writable and defaulted.  The insert is rewritten without them
+// for each run of rows which leave out the same columns.
+type insertColumns struct {
+    head, tail string // the query before the column list and after the values
+    columns    []string
+    values     []string
+    fields     []*reflectx.FieldInfo // the field bound to each value, or nil
+}
+
+// newInsertColumns parses a named insert of structs of type t.  It returns
+// false if the query is not an insert with a column list and plain named
+// values, or if none of its columns has a write control option.
+func newInsertColumns(query string, t reflect.Type, m *reflectx.Mapper) (*insertColumns, bool) {
+    t = reflectx.Deref(t)
+    if t.Kind() != reflect.Struct {
+        return nil, false
+    }
+    loc := valuesClause.FindStringIndex(query)
+    if loc == nil {
+        return nil, false
+    }
+    open := strings.LastIndex(query[:loc[0]], "(")
+    end := strings.Index(query[loc[1]:], ")")
+    if open < 0 || end < 0 {
+        return nil, false
+    }
+    end += loc[1]
+
+    c := &insertColumns{head: query[:open], tail: query[end+1:]}
+    c.columns = strings.Split(query[open+1:loc[0]], ",")
+    c.values = strings.Split(query[loc[1]:end], ",")
+    if len(c.columns) != len(c.values) {
+        return nil, false
+    }
+    names := m.TypeMap(t).Names
+    controlled := false
+    for i := range c.values {
+        c.columns[i] = strings.TrimSpace(c.columns[i])
+        c.values[i] = strings.TrimSpace(c.values[i])
+        if !namedParam.MatchString(c.values[i]) {
+            return nil, false
+        }
+        fi := names[c.values[i][1:]]
+        if fi != nil {
+            _, def := fi.Options["default"]
+            controlled = controlled || def || !writable(fi, &insertMapOptions)
+        }
+        c.fields = append(c.fields, fi)
+    }
+    return c, controlled
+}
+
+// insertMapOptions are the options named inserts are written with.
+var insertMapOptions = MapOptions{Op: MapInsert}
+
+// skip returns which columns the struct item leaves out of the insert.
+func (c *insertColumns) skip(item reflect.Value) []bool {
+    item = reflect.Indirect(item)
+    skip := make([]bool, len(c.fields))
+    for i, fi := range c.fields {
+        switch {
+        case fi == nil:
+        case !writable(fi, &insertMapOptions):
+            skip[i] = true
+        case defaulted(fi, &insertMapOptions):
+            skip[i] = item.IsValid() && isZeroValue(reflectx.FieldByIndexesReadOnly(item, fi.Index), fi)
+        }
+    }
+    return skip
+}
+
+// query returns the insert without the columns in skip.  It is an error to
+// skip every column, as there is no portable insert of an empty column list.
+func (c *insertColumns) query(skip []bool) (string, error) {
+    var columns, values []string
+    for i := range c.columns {
+        if !skip[i] {
+            columns = append(columns, c.columns[i])
+            values = append(values, c.values[i])
+        }
+    }
+    if len(columns) == 0 {
+        return "", fmt.Errorf("sqlx: every column of %s(...) is left out by readonly, insertonly or default fields", strings.TrimSpace(c.head))
+    }
+    return c.head + "(" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(values, ", ") + ")" + c.tail, nil
+}
+
+// exec executes the insert for every element of the batch, splitting it into
+// runs of rows which write the same columns.
+func (c *insertColumns) exec(e Ext, batch reflect.Value, m *reflectx.Mapper) (sql.Result, error) {
+    return c.runs(batch, func(query string, rows reflect.Value) (sql.Result, error) {
+        return namedExecRows(e, query, rows, m)
+    })
+}
+
+// runs calls execRows with the query and rows of every run of rows in the
+// batch which write the same columns.
+func (c *insertColumns) runs(batch reflect.Value, execRows func(string, reflect.Value) (sql.Result, error)) (sql.Result, error) {
+    if batch.Kind() == reflect.Array {
+        s := reflect.MakeSlice(reflect.SliceOf(batch.Type().Elem()), batch.Len(), batch.Len())
+        reflect.Copy(s, batch)
+        batch = s
+    }
+
+    var res batchResult
+    for i := 0; i < batch.Len(); {
+        skip := c.skip(batch.Index(i))
+        j := i + 1
+        for j < batch.Len() && reflect.DeepEqual(skip, c.skip(batch.Index(j))) {
+            j++
+        }
+        query, err := c.query(skip)
+        if err != nil {
+            return nil, err
+        }
+        r, err := execRows(query, batch.Slice(i, j))
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, r)
+        i = j
+    }
+    return res, nil
+}
+
+// namedExecEach executes a prepared NamedStmt for every element of the batch.
+func namedExecEach(e Ext, query string, batch reflect.Value) (sql.Result, error) {
+    var res batchResult
+    p, ok := e.(namedPreparer)
+    if !ok {
+        for i := 0; i < batch.Len(); i++ {
+            r, err := NamedExec(e, query, batch.Index(i).Interface())
+            if err != nil {
+                return nil, err
+            }
+            res = append(res, r)
+        }
+        return res, nil
+    }
+
+    stmt, err := prepareNamed(p, query)
+    if err != nil {
+        return nil, err
+    }
+    defer stmt.Close()
+    for i := 0; i < batch.Len(); i++ {
+        r, err := stmt.Exec(batch.Index(i).Interface())
+        if err != nil {
+            return nil, err
+        }
+        res = append(res, r)
+    }
+    return res, nil
+}
+
+// batchResult is the sql.Result of a batch executed as several statements.
+type batchResult []sql.Result
+
+// LastInsertId returns the id reported by the last statement of the batch.
+func (b batchResult) LastInsertId() (int64, error) {
+    return b[len(b)-1].LastInsertId()
+}
+
+// RowsAffected returns the sum of rows affected by every statement of the batch.
+func (b batchResult) RowsAffected() (int64, error) {
+    var total int64
+    for _, r := range b {
+        n, err := r.RowsAffected()
+        if err != nil {
+            return total, err
+        }
+        total += n
+    }
+    return total, nil
 }
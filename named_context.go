@@ -5,6 +5,9 @@ package sqlx
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"github.com/tietang/sqlx/reflectx"
+	"reflect"
 )
 
 // A union interface of contextPreparer and binder, required to be able to
@@ -36,9 +39,10 @@ func prepareNamedContext(ctx context.Context, p namedPreparerContext, query stri
 func (n *NamedStmt) ExecContext(ctx context.Context, arg interface{}) (sql.Result, error) {
 	args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
 	if err != nil {
-		return *new(sql.Result), err
+		return *new(sql.Result), queryError("exec", n.Stmt, n.QueryString, nil, err)
 	}
-	return n.Stmt.ExecContext(ctx, args...)
+	res, err := n.Stmt.ExecContext(ctx, args...)
+	return res, queryError("exec", n.Stmt, n.QueryString, args, err)
 }
 
 // QueryContext executes a named statement using the struct argument, returning rows.
@@ -46,9 +50,10 @@ func (n *NamedStmt) ExecContext(ctx context.Context, arg interface{}) (sql.Resul
 func (n *NamedStmt) QueryContext(ctx context.Context, arg interface{}) (*sql.Rows, error) {
 	args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
 	if err != nil {
-		return nil, err
+		return nil, queryError("query", n.Stmt, n.QueryString, nil, err)
 	}
-	return n.Stmt.QueryContext(ctx, args...)
+	rows, err := n.Stmt.QueryContext(ctx, args...)
+	return rows, queryError("query", n.Stmt, n.QueryString, args, err)
 }
 
 // QueryRowContext executes a named statement against the database.  Because sqlx cannot
@@ -58,7 +63,7 @@ func (n *NamedStmt) QueryContext(ctx context.Context, arg interface{}) (*sql.Row
 func (n *NamedStmt) QueryRowContext(ctx context.Context, arg interface{}) *Row {
 	args, err := bindAnyArgs(n.Params, arg, n.Stmt.Mapper)
 	if err != nil {
-		return &Row{err: err}
+		return &Row{err: queryError("query", n.Stmt, n.QueryString, nil, err)}
 	}
 	return n.Stmt.QueryRowxContext(ctx, args...)
 }
@@ -80,7 +85,7 @@ func (n *NamedStmt) QueryxContext(ctx context.Context, arg interface{}) (*Rows,
 	if err != nil {
 		return nil, err
 	}
-	return &Rows{Rows: r, Mapper: n.Stmt.Mapper, unsafe: isUnsafe(n)}, err
+	return &Rows{Rows: r, ctx: ctx, Mapper: n.Stmt.Mapper, unsafe: isUnsafe(n)}, err
 }
 
 // QueryRowxContext this NamedStmt.  Because of limitations with QueryRow, this is
@@ -115,18 +120,114 @@ func (n *NamedStmt) GetContext(ctx context.Context, dest interface{}, arg interf
 func NamedQueryContext(ctx context.Context, e ExtContext, query string, arg interface{}) (*Rows, error) {
 	q, args, err := bindNamedMapper(BindType(e.DriverName()), query, arg, mapperFor(e))
 	if err != nil {
-		return nil, err
+		return nil, queryError("named query", e, query, nil, err)
 	}
-	return e.QueryxContext(ctx, q, args...)
+	rows, err := e.QueryxContext(ctx, q, args...)
+	return rows, queryError("named query", e, q, args, err)
 }
 
 // NamedExecContext uses BindStruct to get a query executable by the driver and
 // then runs Exec on the result.  Returns an error from the binding
-// or the query excution itself.
+// or the query excution itself.  Slices and arrays of structs or maps are
+// executed as a batch, as described for NamedExec, within a transaction if e
+// is a *DB.
 func NamedExecContext(ctx context.Context, e ExtContext, query string, arg interface{}) (sql.Result, error) {
+	if batch, ok := batchArg(arg); ok {
+		res, err := namedExecBatchContext(ctx, e, query, batch)
+		return res, queryError("named exec", e, query, nil, err)
+	}
 	q, args, err := bindNamedMapper(BindType(e.DriverName()), query, arg, mapperFor(e))
+	if err != nil {
+		return nil, queryError("named exec", e, query, nil, err)
+	}
+	res, err := e.ExecContext(ctx, q, args...)
+	return res, queryError("named exec", e, q, args, err)
+}
+
+// namedExecBatchContext executes query for every element of the batch.  If e
+// is a *DB, the statements are run within a transaction.
+func namedExecBatchContext(ctx context.Context, e ExtContext, query string, batch reflect.Value) (sql.Result, error) {
+	if batch.Len() == 0 {
+		return nil, errors.New("empty slice passed to NamedExecContext")
+	}
+	if db, ok := e.(*DB); ok {
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := namedExecBatchContext(ctx, tx, query, batch)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		return res, tx.Commit()
+	}
+	m := mapperFor(e)
+	if cols, ok := newInsertColumns(query, batch.Type().Elem(), m); ok {
+		return cols.runs(batch, func(query string, rows reflect.Value) (sql.Result, error) {
+			return namedExecRowsContext(ctx, e, query, rows, m)
+		})
+	}
+	return namedExecRowsContext(ctx, e, query, batch, m)
+}
+
+// namedExecRowsContext executes query for every element of the batch, as
+// multi-row inserts if possible.
+func namedExecRowsContext(ctx context.Context, e ExtContext, query string, batch reflect.Value, m *reflectx.Mapper) (sql.Result, error) {
+	ins, ok, err := newBatchInsert(query, e.DriverName())
 	if err != nil {
 		return nil, err
 	}
-	return e.ExecContext(ctx, q, args...)
+	if !ok {
+		return namedExecEachContext(ctx, e, query, batch)
+	}
+
+	var res batchResult
+	for i := 0; i < batch.Len(); i += ins.perStmt {
+		j := i + ins.perStmt
+		if j > batch.Len() {
+			j = batch.Len()
+		}
+		q, args, err := ins.chunk(batch, i, j, m)
+		if err != nil {
+			return nil, err
+		}
+		r, err := e.ExecContext(ctx, q, args...)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// namedExecEachContext executes a prepared NamedStmt for every element of the
+// batch.
+func namedExecEachContext(ctx context.Context, e ExtContext, query string, batch reflect.Value) (sql.Result, error) {
+	var res batchResult
+	p, ok := e.(namedPreparerContext)
+	if !ok {
+		for i := 0; i < batch.Len(); i++ {
+			r, err := NamedExecContext(ctx, e, query, batch.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, r)
+		}
+		return res, nil
+	}
+
+	stmt, err := prepareNamedContext(ctx, p, query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	for i := 0; i < batch.Len(); i++ {
+		r, err := stmt.ExecContext(ctx, batch.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
 }
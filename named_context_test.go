@@ -5,6 +5,7 @@ package sqlx
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"testing"
 )
 
@@ -134,3 +135,31 @@ func TestNamedContextQueries(t *testing.T) {
 
 	})
 }
+
+func TestNamedBatchExecAtomicContext(t *testing.T) {
+	var schema = Schema{
+		create: `CREATE TABLE tag (name varchar(64) NOT NULL UNIQUE);`,
+		drop:   `drop table tag;`,
+	}
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		perStmt := maxBindParams(db.DriverName())
+		if perStmt > 1000 {
+			// only drivers with small bindvar limits are cheap to cross
+			return
+		}
+		// the last chunk repeats the first name
+		tags := make([]map[string]interface{}, 2*perStmt+1)
+		for i := range tags {
+			tags[i] = map[string]interface{}{"name": fmt.Sprint("tag", i%(2*perStmt))}
+		}
+		_, err := db.NamedExecContext(context.Background(), `INSERT INTO tag (name) VALUES (:name)`, tags)
+		if !IsUniqueViolation(err) {
+			t.Fatalf("expected a unique violation, got %v", err)
+		}
+		var count int
+		if err = db.QueryRowx("SELECT count(*) FROM tag").Scan(&count); err != nil || count != 0 {
+			t.Errorf("expected no tags inserted by a failed batch, got %d, %v", count, err)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package sqlx
 
 import (
 	"database/sql"
+	"fmt"
 	"testing"
 )
 
@@ -242,3 +243,183 @@ func TestNamedQueries(t *testing.T) {
 
 	})
 }
+
+func TestExpandValues(t *testing.T) {
+	table := []struct {
+		Q, R string
+		N    int
+		OK   bool
+	}{
+		{
+			Q:  `INSERT INTO foo (a, b) VALUES (?, ?)`,
+			R:  `INSERT INTO foo (a, b) VALUES (?, ?), (?, ?), (?, ?)`,
+			N:  3,
+			OK: true,
+		},
+		{
+			Q:  `INSERT INTO foo (a, b) values (?, lower(?)) ON CONFLICT DO NOTHING`,
+			R:  `INSERT INTO foo (a, b) values (?, lower(?)), (?, lower(?)) ON CONFLICT DO NOTHING`,
+			N:  2,
+			OK: true,
+		},
+		{
+			Q: `UPDATE foo SET a = ? WHERE b = ?`,
+			R: `UPDATE foo SET a = ? WHERE b = ?`,
+			N: 2,
+		},
+	}
+
+	for _, test := range table {
+		q, ok := expandValues(test.Q, test.N)
+		if ok != test.OK {
+			t.Errorf("expected ok to be %t for %s", test.OK, test.Q)
+		}
+		if q != test.R {
+			t.Errorf("\nexpected: `%s`\ngot:      `%s`", test.R, q)
+		}
+	}
+}
+
+func TestNewBatchInsert(t *testing.T) {
+	ins, ok, err := newBatchInsert(`INSERT INTO foo (a, b) VALUES (:a, :b)`, "postgres")
+	if err != nil || !ok {
+		t.Fatalf("expected a batch insert, got %v, %v", ok, err)
+	}
+	if ins.perStmt != 65535/2 || len(ins.names) != 2 {
+		t.Errorf("unexpected batch insert %#v", ins)
+	}
+
+	// names outside the tuple are bound once per statement, not per row
+	_, ok, err = newBatchInsert(`INSERT INTO foo (a, b) VALUES (:a, :b) ON CONFLICT (a) DO UPDATE SET b = :b`, "postgres")
+	if err != nil || ok {
+		t.Errorf("expected an upsert with names outside the tuple to run per row, got %v, %v", ok, err)
+	}
+	_, ok, err = newBatchInsert(`INSERT INTO foo (a, b) VALUES (:a, :b) ON CONFLICT DO NOTHING`, "postgres")
+	if err != nil || !ok {
+		t.Errorf("expected a batch insert, got %v, %v", ok, err)
+	}
+}
+
+func TestNamedBatchExecChunks(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		perStmt := maxBindParams(db.DriverName()) / 3
+		if perStmt > 1000 {
+			// only drivers with small bindvar limits are cheap to cross
+			return
+		}
+		people := make([]Person, 2*perStmt+1)
+		for i := range people {
+			people[i] = Person{FirstName: fmt.Sprint("first", i), LastName: "chunk", Email: fmt.Sprint(i, "@chunk")}
+		}
+		res, err := db.NamedExec(`INSERT INTO person (first_name, last_name, email)
+			VALUES (:first_name, :last_name, :email)`, people)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := res.(batchResult); !ok || len(b) != 3 {
+			t.Errorf("expected the batch to be split into 3 statements, got %#v", res)
+		}
+		n, err := res.RowsAffected()
+		if err != nil || n != int64(len(people)) {
+			t.Errorf("expected %d rows affected, got %d, %v", len(people), n, err)
+		}
+		var count int
+		if err = db.QueryRowx("SELECT count(*) FROM person WHERE last_name = 'chunk'").Scan(&count); err != nil || count != len(people) {
+			t.Errorf("expected %d people inserted, got %d, %v", len(people), count, err)
+		}
+	})
+}
+
+func TestNamedBatchExecAtomic(t *testing.T) {
+	var schema = Schema{
+		create: `CREATE TABLE tag (name varchar(64) NOT NULL UNIQUE);`,
+		drop:   `drop table tag;`,
+	}
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		perStmt := maxBindParams(db.DriverName())
+		if perStmt > 1000 {
+			// only drivers with small bindvar limits are cheap to cross
+			return
+		}
+		// the last chunk repeats the first name
+		tags := make([]map[string]interface{}, 2*perStmt+1)
+		for i := range tags {
+			tags[i] = map[string]interface{}{"name": fmt.Sprint("tag", i%(2*perStmt))}
+		}
+		_, err := db.NamedExec(`INSERT INTO tag (name) VALUES (:name)`, tags)
+		if !IsUniqueViolation(err) {
+			t.Fatalf("expected a unique violation, got %v", err)
+		}
+		var count int
+		if err = db.QueryRowx("SELECT count(*) FROM tag").Scan(&count); err != nil || count != 0 {
+			t.Errorf("expected no tags inserted by a failed batch, got %d, %v", count, err)
+		}
+
+		// a Tx leaves the outcome to its owner
+		tx := db.MustBegin()
+		_, err = tx.NamedExec(`INSERT INTO tag (name) VALUES (:name)`, tags)
+		if !IsUniqueViolation(err) {
+			t.Fatalf("expected a unique violation, got %v", err)
+		}
+		if err = tx.QueryRowx("SELECT count(*) FROM tag").Scan(&count); err != nil || count != 2*perStmt {
+			t.Errorf("expected the earlier chunks in the transaction, got %d, %v", count, err)
+		}
+		tx.Rollback()
+	})
+}
+
+func TestNamedBatchExec(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		test := Test{t}
+		people := []Person{
+			{FirstName: "Ardie", LastName: "Savea", Email: "asavea@ab.co.nz"},
+			{FirstName: "Sonny Bill", LastName: "Williams", Email: "sbw@ab.co.nz"},
+			{FirstName: "Ngani", LastName: "Laumape", Email: "nlaumape@ab.co.nz"},
+		}
+
+		res, err := db.NamedExec(`INSERT INTO person (first_name, last_name, email)
+			VALUES (:first_name, :last_name, :email)`, people)
+		test.Error(err)
+		n, err := res.RowsAffected()
+		test.Error(err)
+		if n != 3 {
+			t.Errorf("expected 3 rows affected, got %d", n)
+		}
+
+		// statements without a VALUES clause are run once per element
+		res, err = db.NamedExec(`UPDATE person SET last_name = :first_name WHERE email = :email`, people[:2])
+		test.Error(err)
+		n, err = res.RowsAffected()
+		test.Error(err)
+		if n != 2 {
+			t.Errorf("expected 2 rows affected, got %d", n)
+		}
+
+		var count int
+		err = db.QueryRowx(db.Rebind("SELECT count(*) FROM person WHERE last_name = first_name")).Scan(&count)
+		test.Error(err)
+		if count != 2 {
+			t.Errorf("expected 2 updated people, got %d", count)
+		}
+
+		maps := []map[string]interface{}{
+			{"first_name": "Beauden", "last_name": "Barrett", "email": "bbarrett@ab.co.nz"},
+			{"first_name": "Jordie", "last_name": "Barrett", "email": "jbarrett@ab.co.nz"},
+		}
+		_, err = db.NamedExec(`INSERT INTO person (first_name, last_name, email)
+			VALUES (:first_name, :last_name, :email)`, maps)
+		test.Error(err)
+
+		err = db.QueryRowx(db.Rebind("SELECT count(*) FROM person WHERE last_name = ?"), "Barrett").Scan(&count)
+		test.Error(err)
+		if count != 2 {
+			t.Errorf("expected 2 people named Barrett, got %d", count)
+		}
+
+		_, err = db.NamedExec(`INSERT INTO person (first_name) VALUES (:first_name)`, []Person{})
+		if err == nil {
+			t.Error("expected an error for an empty batch")
+		}
+	})
+}
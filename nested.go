@@ -0,0 +1,121 @@
+package sqlx
+
+import (
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+)
+
+// nullGroup is a pointer to a struct whose fields are mapped to columns with a
+// prefix option, such as the joined side of a LEFT JOIN:
+//
+//     type UserAddress struct {
+//         User
+//         Address *Address `db:"address,prefix=addr_"`
+//     }
+//
+// The columns of a group are scanned into temporaries, and the pointer is left
+// nil when all of them are NULL instead of pointing to a zero struct.
+type nullGroup struct {
+    index   []int          // traversal of the pointer field
+    columns []int          // positions of the group's columns
+    fields  [][]int        // traversal of each column within the struct
+    types   []reflect.Type // pointer to the type of each column's field
+}
+
+// nullGroups finds the prefixed pointer fields of t that columns with the given
+// traversals are scanned into.  A column belongs to the outermost such field on
+// its traversal.  The traversals of grouped columns are returned as empty, so
+// they are not allocated by the ordinary scanning of the struct.
+func nullGroups(m *reflectx.Mapper, t reflect.Type, traversals [][]int) ([]nullGroup, [][]int) {
+    tm := m.TypeMap(t)
+    var groups []nullGroup
+    var scan [][]int
+
+    for i, traversal := range traversals {
+        for k := 1; k < len(traversal); k++ {
+            fi := tm.GetByTraversal(traversal[:k])
+            if fi == nil || fi.Field.Type.Kind() != reflect.Ptr {
+                continue
+            }
+            if _, ok := fi.Options["prefix"]; !ok {
+                continue
+            }
+
+            if scan == nil {
+                scan = make([][]int, len(traversals))
+                copy(scan, traversals)
+            }
+            scan[i] = []int{}
+
+            g := len(groups) - 1
+            for ; g >= 0; g-- {
+                if sameIndex(groups[g].index, traversal[:k]) {
+                    break
+                }
+            }
+            if g < 0 {
+                groups = append(groups, nullGroup{index: traversal[:k]})
+                g = len(groups) - 1
+            }
+            field := tm.GetByTraversal(traversal)
+            groups[g].columns = append(groups[g].columns, i)
+            groups[g].fields = append(groups[g].fields, traversal[k:])
+            groups[g].types = append(groups[g].types, reflect.PtrTo(field.Field.Type))
+            break
+        }
+    }
+
+    if scan == nil {
+        scan = traversals
+    }
+    return groups, scan
+}
+
+func sameIndex(a, b []int) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+// bindNullGroups points the values of grouped columns at fresh temporaries.
+func bindNullGroups(groups []nullGroup, values []interface{}) {
+    for _, g := range groups {
+        for j, c := range g.columns {
+            values[c] = reflect.New(g.types[j]).Interface()
+        }
+    }
+}
+
+// setNullGroups copies the scanned temporaries into v, allocating a new struct
+// for every group with a non-NULL column and setting the others to nil.
+func setNullGroups(v reflect.Value, groups []nullGroup, values []interface{}) {
+    for _, g := range groups {
+        last := len(g.index) - 1
+        ptr := reflect.Indirect(reflectx.FieldByIndexes(v, g.index[:last])).Field(g.index[last])
+
+        null := true
+        for _, c := range g.columns {
+            if !reflect.ValueOf(values[c]).Elem().IsNil() {
+                null = false
+                break
+            }
+        }
+        if null {
+            ptr.Set(reflect.Zero(ptr.Type()))
+            continue
+        }
+
+        ptr.Set(reflect.New(ptr.Type().Elem()))
+        for j, c := range g.columns {
+            if src := reflect.ValueOf(values[c]).Elem(); !src.IsNil() {
+                reflectx.FieldByIndexes(ptr, g.fields[j]).Set(src.Elem())
+            }
+        }
+    }
+}
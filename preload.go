@@ -0,0 +1,315 @@
+// +build go1.8
+
+package sqlx
+
+import (
+    "context"
+    "database/sql/driver"
+    "fmt"
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+    "strings"
+)
+
+// relation is a has_one or has_many association declared on a struct field
+// with a `rel` tag:
+//
+//     type User struct {
+//         ID     int64    `db:"id"`
+//         Orders []Order  `db:"-" rel:"has_many,fk=user_id"`
+//         Avatar *Avatar  `db:"-" rel:"has_one,fk=user_id,table=avatars"`
+//     }
+//
+// fk is the column of the child table referencing the parent.  key is the
+// parent column it references, "id" by default, and table is the child table,
+// by default the snake cased name of the child type as used by Map.  order is
+// an ORDER BY term for the children, eg. "order=created_at DESC".  A has_one
+// relation which matches several children keeps the first one in that order,
+// and is an error without an order.
+type relation struct {
+    name  string
+    many  bool
+    index []int        // traversal of the relation field in the parent
+    elem  reflect.Type // type of the field or of its slice elements
+    base  reflect.Type // child struct type
+    key   string
+    fk    string
+    table string
+    order string
+}
+
+func parseRelation(t reflect.Type, name string) (*relation, error) {
+    f, ok := t.FieldByName(name)
+    if !ok {
+        return nil, fmt.Errorf("sqlx: %s has no field %s", t, name)
+    }
+    tag, ok := f.Tag.Lookup("rel")
+    if !ok {
+        return nil, fmt.Errorf("sqlx: field %s of %s has no rel tag", name, t)
+    }
+
+    r := &relation{name: name, index: f.Index, elem: f.Type, key: "id"}
+    parts := strings.Split(tag, ",")
+    switch parts[0] {
+    case "has_one":
+    case "has_many":
+        if f.Type.Kind() != reflect.Slice {
+            return nil, fmt.Errorf("sqlx: has_many field %s of %s is not a slice", name, t)
+        }
+        r.many = true
+        r.elem = f.Type.Elem()
+    default:
+        return nil, fmt.Errorf("sqlx: unknown relation %q on field %s of %s", parts[0], name, t)
+    }
+    for _, opt := range parts[1:] {
+        kv := strings.SplitN(opt, "=", 2)
+        if len(kv) != 2 {
+            continue
+        }
+        switch kv[0] {
+        case "fk":
+            r.fk = kv[1]
+        case "key":
+            r.key = kv[1]
+        case "table":
+            r.table = kv[1]
+        case "order":
+            r.order = kv[1]
+        }
+    }
+
+    r.base = reflectx.Deref(r.elem)
+    if r.base.Kind() != reflect.Struct {
+        return nil, fmt.Errorf("sqlx: relation %s of %s is not a struct", name, t)
+    }
+    if r.fk == "" {
+        return nil, fmt.Errorf("sqlx: relation %s of %s has no fk", name, t)
+    }
+    if r.table == "" {
+        r.table = snakeCasedName(r.base.Name())
+    }
+    return r, nil
+}
+
+// Preload loads the relations named by paths into dest, a pointer to a struct
+// or to a slice of structs, issuing one query per relation:
+//
+//     Preload(ctx, db, &users, "Orders", "Orders.Items")
+//
+// Each query selects the child rows whose fk is IN the keys of all the parents,
+// so a path of n relations costs n queries however many parents there are.
+// Keys beyond the bindvar limit of the driver are split over several queries.
+// Intermediate relations of a path are loaded even if not listed themselves.
+// Children with a softdelete column which are marked deleted are skipped,
+// unless q is Unscoped.
+func Preload(ctx context.Context, q QueryerContext, dest interface{}, paths ...string) error {
+    v := reflect.ValueOf(dest)
+    if v.Kind() != reflect.Ptr || v.IsNil() {
+        return ErrExpectingPointer
+    }
+    v = v.Elem()
+
+    var parents []reflect.Value
+    if v.Kind() == reflect.Slice {
+        for i := 0; i < v.Len(); i++ {
+            parents = appendStruct(parents, v.Index(i))
+        }
+    } else {
+        parents = appendStruct(parents, v)
+    }
+    if len(parents) == 0 {
+        return nil
+    }
+
+    tree := preloadTree{}
+    for _, path := range paths {
+        tree.add(strings.Split(path, "."))
+    }
+    return tree.load(ctx, q, mapperFor(q), parents)
+}
+
+// preloadTree holds the relations to load on a type and, for each, the
+// relations to load on its children, in the order they were given.
+type preloadTree struct {
+    names    []string
+    children map[string]*preloadTree
+}
+
+func (t *preloadTree) add(names []string) {
+    if len(names) == 0 {
+        return
+    }
+    if t.children == nil {
+        t.children = map[string]*preloadTree{}
+    }
+    child, ok := t.children[names[0]]
+    if !ok {
+        child = &preloadTree{}
+        t.children[names[0]] = child
+        t.names = append(t.names, names[0])
+    }
+    child.add(names[1:])
+}
+
+func (t *preloadTree) load(ctx context.Context, q QueryerContext, m *reflectx.Mapper, parents []reflect.Value) error {
+    for _, name := range t.names {
+        r, err := parseRelation(parents[0].Type(), name)
+        if err != nil {
+            return err
+        }
+        children, err := r.load(ctx, q, m, parents)
+        if err != nil {
+            return err
+        }
+        if len(children) > 0 {
+            if err = t.children[name].load(ctx, q, m, children); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// load queries the children of parents and sets them on the relation field,
+// returning the children as addressable structs.
+func (r *relation) load(ctx context.Context, q QueryerContext, m *reflectx.Mapper, parents []reflect.Value) ([]reflect.Value, error) {
+    pk, ok := m.TypeMap(parents[0].Type()).Names[r.key]
+    if !ok {
+        return nil, fmt.Errorf("sqlx: relation %s: missing key %s in %s", r.name, r.key, parents[0].Type())
+    }
+    fk, ok := m.TypeMap(r.base).Names[r.fk]
+    if !ok {
+        return nil, fmt.Errorf("sqlx: relation %s: missing fk %s in %s", r.name, r.fk, r.base)
+    }
+
+    var keys []interface{}
+    seen := map[interface{}]bool{}
+    for _, p := range parents {
+        k := relationKey(reflectx.FieldByIndexesReadOnly(p, pk.Index))
+        if k == nil || seen[k] {
+            continue
+        }
+        seen[k] = true
+        keys = append(keys, k)
+    }
+    if len(keys) == 0 {
+        return nil, nil
+    }
+
+    query := fmt.Sprintf("SELECT * FROM %s WHERE %s IN (?)", r.table, r.fk)
+    if deleted, _ := softDeleteColumn(m, r.base); deleted != "" && !isUnscoped(q) {
+        query += " AND " + deleted + " IS NULL"
+    }
+    if r.order != "" {
+        query += " ORDER BY " + r.order
+    }
+    b, isBinder := q.(binder)
+    perQuery := maxBindParams("")
+    if isBinder {
+        perQuery = maxBindParams(b.DriverName())
+    }
+
+    // the keys of a parent are all in one chunk, so the children of each
+    // parent keep the order of the query
+    rows := reflect.New(reflect.SliceOf(reflect.PtrTo(r.base)))
+    for i := 0; i < len(keys); i += perQuery {
+        j := i + perQuery
+        if j > len(keys) {
+            j = len(keys)
+        }
+        chunk, args, err := In(query, keys[i:j])
+        if err != nil {
+            return nil, err
+        }
+        if isBinder {
+            chunk = b.Rebind(chunk)
+        }
+        part := reflect.New(rows.Elem().Type())
+        if err = SelectContext(ctx, q, part.Interface(), chunk, args...); err != nil {
+            return nil, err
+        }
+        rows.Elem().Set(reflect.AppendSlice(rows.Elem(), part.Elem()))
+    }
+
+    byKey := map[interface{}][]reflect.Value{}
+    for i := 0; i < rows.Elem().Len(); i++ {
+        child := rows.Elem().Index(i)
+        k := relationKey(reflectx.FieldByIndexesReadOnly(child, fk.Index))
+        byKey[k] = append(byKey[k], child)
+    }
+
+    var loaded []reflect.Value
+    for _, p := range parents {
+        found := byKey[relationKey(reflectx.FieldByIndexesReadOnly(p, pk.Index))]
+        if len(found) == 0 {
+            continue
+        }
+        field := p.FieldByIndex(r.index)
+        if !r.many {
+            if len(found) > 1 && r.order == "" {
+                return nil, fmt.Errorf("sqlx: has_one relation %s of %s has %d rows in %s for one key, give it an order",
+                    r.name, p.Type(), len(found), r.table)
+            }
+            field.Set(relationValue(found[0], r.elem))
+            loaded = appendStruct(loaded, field)
+            continue
+        }
+        slice := reflect.MakeSlice(field.Type(), len(found), len(found))
+        for i, child := range found {
+            slice.Index(i).Set(relationValue(child, r.elem))
+            loaded = appendStruct(loaded, slice.Index(i))
+        }
+        field.Set(slice)
+    }
+    return loaded, nil
+}
+
+// relationValue converts the scanned *T child to the field type, T or *T.
+func relationValue(child reflect.Value, t reflect.Type) reflect.Value {
+    if t.Kind() == reflect.Ptr {
+        return child
+    }
+    return child.Elem()
+}
+
+// appendStruct appends the struct v, or the struct it points to, unless nil.
+func appendStruct(structs []reflect.Value, v reflect.Value) []reflect.Value {
+    if v.Kind() == reflect.Ptr {
+        if v.IsNil() {
+            return structs
+        }
+        v = v.Elem()
+    }
+    return append(structs, v)
+}
+
+// relationKey normalizes a key or fk field so that the values of parents and
+// children compare equal whatever their integer or nullable types.
+func relationKey(v reflect.Value) interface{} {
+    if !v.IsValid() {
+        return nil
+    }
+    i := v.Interface()
+    if valuer, ok := i.(driver.Valuer); ok {
+        var err error
+        if i, err = valuer.Value(); err != nil {
+            return nil
+        }
+    }
+    switch k := i.(type) {
+    case nil:
+        return nil
+    case []byte:
+        return string(k)
+    }
+    rv := reflect.Indirect(reflect.ValueOf(i))
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return rv.Int()
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return int64(rv.Uint())
+    case reflect.Invalid:
+        return nil
+    }
+    return rv.Interface()
+}
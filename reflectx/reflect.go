@@ -25,6 +25,14 @@ type FieldInfo struct {
 	Embedded bool
 	Children []*FieldInfo
 	Parent   *FieldInfo
+	column   string
+}
+
+// Column returns the column name of the field, which is its key in the Names
+// of its StructMap:  its Name, prefixed with the path or prefix option of the
+// structs it is nested in.
+func (f *FieldInfo) Column() string {
+	return f.column
 }
 
 // A StructMap is an index of field metadata for a struct.
@@ -60,19 +68,31 @@ func (f StructMap) GetByTraversal(index []int) *FieldInfo {
 // Mapper is a general purpose mapper of names to struct fields.  A Mapper
 // behaves like most marshallers, optionally obeying a field tag for name
 // mapping and a function to provide a basic mapping of fields to names.
+//
+// Mappings are cached per type.  Lookups of cached types take no locks, and
+// concurrent first lookups of a type compute its mapping only once.
 type Mapper struct {
-	cache      map[reflect.Type]*StructMap
+	cache      sync.Map // reflect.Type -> *StructMap
 	tagName    string
 	tagMapFunc func(string) string
 	mapFunc    func(string) string
-	mutex      sync.Mutex
+	mutex      sync.Mutex // guards calls, size and limit
+	calls      map[reflect.Type]*mappingCall
+	size       int
+	limit      int
+}
+
+// mappingCall is an in-flight computation of a type's mapping, waited on by
+// concurrent lookups of the same type.
+type mappingCall struct {
+	wg      sync.WaitGroup
+	mapping *StructMap
 }
 
 // NewMapper returns a new mapper which optionally obeys the field tag given
 // by tagName.  If tagName is the empty string, it is ignored.
 func NewMapper(tagName string) *Mapper {
 	return &Mapper{
-		cache:   make(map[reflect.Type]*StructMap),
 		tagName: tagName,
 	}
 }
@@ -82,7 +102,6 @@ func NewMapper(tagName string) *Mapper {
 // have values like "name,omitempty".
 func NewMapperTagFunc(tagName string, mapFunc, tagMapFunc func(string) string) *Mapper {
 	return &Mapper{
-		cache:      make(map[reflect.Type]*StructMap),
 		tagName:    tagName,
 		mapFunc:    mapFunc,
 		tagMapFunc: tagMapFunc,
@@ -94,7 +113,6 @@ func NewMapperTagFunc(tagName string, mapFunc, tagMapFunc func(string) string) *
 // for any other field, the mapped name will be f(field.Name)
 func NewMapperFunc(tagName string, f func(string) string) *Mapper {
 	return &Mapper{
-		cache:   make(map[reflect.Type]*StructMap),
 		tagName: tagName,
 		mapFunc: f,
 	}
@@ -103,14 +121,105 @@ func NewMapperFunc(tagName string, f func(string) string) *Mapper {
 // TypeMap returns a mapping of field strings to int slices representing
 // the traversal down the struct to reach the field.
 func (m *Mapper) TypeMap(t reflect.Type) *StructMap {
+	t = Deref(t)
+	if mapping, ok := m.cache.Load(t); ok {
+		return mapping.(*StructMap)
+	}
+	return m.load(t)
+}
+
+// load computes and caches the mapping for t, or waits for a concurrent
+// computation of it to finish.
+func (m *Mapper) load(t reflect.Type) *StructMap {
 	m.mutex.Lock()
-	mapping, ok := m.cache[t]
-	if !ok {
-		mapping = getMapping(t, m.tagName, m.mapFunc, m.tagMapFunc)
-		m.cache[t] = mapping
+	if mapping, ok := m.cache.Load(t); ok {
+		m.mutex.Unlock()
+		return mapping.(*StructMap)
+	}
+	if c, ok := m.calls[t]; ok {
+		m.mutex.Unlock()
+		c.wg.Wait()
+		return c.mapping
 	}
+	c := &mappingCall{}
+	c.wg.Add(1)
+	if m.calls == nil {
+		m.calls = make(map[reflect.Type]*mappingCall)
+	}
+	m.calls[t] = c
 	m.mutex.Unlock()
-	return mapping
+
+	defer func() {
+		m.mutex.Lock()
+		if c.mapping != nil {
+			m.store(t, c.mapping)
+		}
+		delete(m.calls, t)
+		m.mutex.Unlock()
+		c.wg.Done()
+	}()
+	c.mapping = getMapping(t, m.tagName, m.mapFunc, m.tagMapFunc)
+	return c.mapping
+}
+
+// store caches mapping for t, evicting another type if the cache is full.
+// The mutex must be held.
+func (m *Mapper) store(t reflect.Type, mapping *StructMap) {
+	if _, loaded := m.cache.LoadOrStore(t, mapping); loaded {
+		return
+	}
+	m.size++
+	if m.limit > 0 && m.size > m.limit {
+		m.cache.Range(func(k, _ interface{}) bool {
+			if k == t {
+				return true
+			}
+			m.cache.Delete(k)
+			m.size--
+			return m.size > m.limit
+		})
+	}
+}
+
+// SetCacheLimit bounds the number of types whose mappings are cached to n,
+// which is useful for programs that create many struct types at runtime.
+// When the cache is full an arbitrary type is evicted to make room.  A limit
+// of 0, the default, leaves the cache unbounded.
+func (m *Mapper) SetCacheLimit(n int) {
+	m.mutex.Lock()
+	m.limit = n
+	if n > 0 && m.size > n {
+		m.cache.Range(func(k, _ interface{}) bool {
+			m.cache.Delete(k)
+			m.size--
+			return m.size > n
+		})
+	}
+	m.mutex.Unlock()
+}
+
+// Purge drops every cached mapping.
+func (m *Mapper) Purge() {
+	m.mutex.Lock()
+	m.cache.Range(func(k, _ interface{}) bool {
+		m.cache.Delete(k)
+		return true
+	})
+	m.size = 0
+	m.mutex.Unlock()
+}
+
+// Preload computes and caches the mappings of the given types, so that the
+// first queries at runtime don't pay for it.  Each argument may be a
+// reflect.Type or a value of (or pointer to) the struct type to map.
+func (m *Mapper) Preload(types ...interface{}) {
+	for _, v := range types {
+		t, ok := v.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(v)
+		}
+		m.TypeMap(t)
+	}
 }
 
 // FieldMap returns the mapper's mapping of field names to reflect values.  Panics
@@ -305,6 +414,7 @@ type typeQueue struct {
 	t  reflect.Type
 	fi *FieldInfo
 	pp string // Parent path
+	np string // Prefix of the children's column names
 }
 
 // A copying append that creates a new slice each time.
@@ -324,7 +434,7 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 
 	root := &FieldInfo{}
 	queue := []typeQueue{}
-	queue = append(queue, typeQueue{Deref(t), root, ""})
+	queue = append(queue, typeQueue{Deref(t), root, "", ""})
 
 	for len(queue) != 0 {
 		// pop the first item off of the queue
@@ -368,6 +478,15 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 				}
 			}
 
+			// "-,scanonly" still maps the field under its untagged name, so
+			// that it is scanned but never written
+			if _, ok := fi.Options["scanonly"]; ok && name == "-" {
+				name = f.Name
+				if mapFunc != nil {
+					name = mapFunc(f.Name)
+				}
+			}
+
 			if tagMapFunc != nil {
 				tag = tagMapFunc(tag)
 			}
@@ -379,6 +498,17 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 			} else {
 				fi.Path = fmt.Sprintf("%s.%s", tq.pp, fi.Name)
 			}
+			fi.column = tq.np + fi.Name
+
+			// the column names of a nested struct are prefixed with its path,
+			// or with the value of its prefix option
+			np := ""
+			if fi.column != "" {
+				np = fi.column + "."
+			}
+			if prefix, ok := fi.Options["prefix"]; ok {
+				np = tq.np + prefix
+			}
 
 			// if the name is "-", disabled via a tag, skip it
 			if name == "-" {
@@ -392,9 +522,9 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 
 			// bfs search of anonymous embedded structs
 			if f.Anonymous {
-				pp := tq.pp
+				pp, enp := tq.pp, tq.np
 				if tag != "" {
-					pp = fi.Path
+					pp, enp = fi.Path, np
 				}
 
 				fi.Embedded = true
@@ -405,11 +535,11 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 					nChildren = ft.NumField()
 				}
 				fi.Children = make([]*FieldInfo, nChildren)
-				queue = append(queue, typeQueue{Deref(f.Type), &fi, pp})
+				queue = append(queue, typeQueue{Deref(f.Type), &fi, pp, enp})
 			} else if fi.Zero.Kind() == reflect.Struct || (fi.Zero.Kind() == reflect.Ptr && fi.Zero.Type().Elem().Kind() == reflect.Struct) {
 				fi.Index = apnd(tq.fi.Index, fieldPos)
 				fi.Children = make([]*FieldInfo, Deref(f.Type).NumField())
-				queue = append(queue, typeQueue{Deref(f.Type), &fi, fi.Path})
+				queue = append(queue, typeQueue{Deref(f.Type), &fi, fi.Path, np})
 			}
 
 			fi.Index = apnd(tq.fi.Index, fieldPos)
@@ -423,7 +553,7 @@ func getMapping(t reflect.Type, tagName string, mapFunc, tagMapFunc func(string)
 	for _, fi := range flds.Index {
 		flds.Paths[fi.Path] = fi
 		if fi.Name != "" && !fi.Embedded {
-			flds.Names[fi.Path] = fi
+			flds.Names[fi.column] = fi
 		}
 	}
 
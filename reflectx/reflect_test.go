@@ -3,6 +3,7 @@ package reflectx
 import (
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -508,6 +509,140 @@ func TestMapping(t *testing.T) {
 	}
 }
 
+func TestPrefixedNames(t *testing.T) {
+	type Geo struct {
+		Lat float64
+	}
+	type Address struct {
+		City string
+		Geo  Geo `db:"geo,prefix=g_"`
+	}
+	type Contact struct {
+		Phone string
+	}
+	type User struct {
+		Name    string
+		Contact `db:"contact,prefix=c_"`
+		Address *Address `db:"address,prefix=addr_"`
+		Home    Address  `db:"home"`
+	}
+
+	m := NewMapperFunc("db", strings.ToLower)
+	mapping := m.TypeMap(reflect.TypeOf(User{}))
+
+	names := map[string]string{
+		"name":       "Name",
+		"c_phone":    "Phone",
+		"addr_city":  "City",
+		"addr_g_lat": "Lat",
+		"home.city":  "City",
+		"home.g_lat": "Lat",
+		"address":    "Address",
+		"addr_geo":   "Geo",
+		"home.geo":   "Geo",
+	}
+	for name, field := range names {
+		fi, ok := mapping.Names[name]
+		if !ok {
+			t.Errorf("expected a field named %q", name)
+			continue
+		}
+		if fi.Field.Name != field {
+			t.Errorf("expected %q to map to %s, got %s", name, field, fi.Field.Name)
+		}
+	}
+	if _, ok := mapping.Names["address.city"]; ok {
+		t.Error("expected the prefix to replace the path of a nested field")
+	}
+	if fi := mapping.GetByPath("address.geo.lat"); fi == nil || len(fi.Index) != 3 {
+		t.Errorf("expected paths to be unaffected by prefixes, got %#v", fi)
+	}
+}
+
+func TestScanOnlyNames(t *testing.T) {
+	type Order struct {
+		ID    int
+		Total int    `db:"-,scanonly"`
+		Notes string `db:"-"`
+	}
+
+	m := NewMapperFunc("db", strings.ToLower)
+	mapping := m.TypeMap(reflect.TypeOf(Order{}))
+
+	fi, ok := mapping.Names["total"]
+	if !ok {
+		t.Fatal("expected a scanonly field to be mapped under its field name")
+	}
+	if _, ok := fi.Options["scanonly"]; !ok {
+		t.Errorf("expected the scanonly option, got %v", fi.Options)
+	}
+	if _, ok := mapping.Names["notes"]; ok {
+		t.Error("expected a field tagged - to be skipped")
+	}
+}
+
+func TestMapperCache(t *testing.T) {
+	m := NewMapperFunc("db", strings.ToLower)
+	typ := reflect.TypeOf(E4{})
+
+	// concurrent first lookups share one mapping
+	var wg sync.WaitGroup
+	mappings := make([]*StructMap, 16)
+	for i := range mappings {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mappings[i] = m.TypeMap(typ)
+		}(i)
+	}
+	wg.Wait()
+	for _, mapping := range mappings {
+		if mapping != mappings[0] {
+			t.Fatal("expected every lookup to return the same mapping")
+		}
+	}
+	if m.TypeMap(reflect.PtrTo(typ)) != mappings[0] {
+		t.Error("expected pointer and struct types to share a mapping")
+	}
+
+	m.Purge()
+	if m.TypeMap(typ) == mappings[0] {
+		t.Error("expected Purge to drop the cached mapping")
+	}
+
+	m.Purge()
+	m.Preload(E1{}, &E2{}, reflect.TypeOf(E3{}))
+	if m.size != 3 {
+		t.Errorf("expected 3 preloaded types, got %d", m.size)
+	}
+
+	m.SetCacheLimit(2)
+	if m.size != 2 {
+		t.Errorf("expected the cache to shrink to 2 types, got %d", m.size)
+	}
+	e4 := m.TypeMap(typ)
+	if m.size != 2 {
+		t.Errorf("expected the cache to hold 2 types, got %d", m.size)
+	}
+	if m.TypeMap(typ) != e4 {
+		t.Error("expected the most recent type to stay cached")
+	}
+	if fi := e4.Names["a"]; fi == nil || len(fi.Index) != 4 {
+		t.Errorf("unexpected mapping for E4: %#v", fi)
+	}
+}
+
+func BenchmarkTypeMapParallel(b *testing.B) {
+	m := NewMapperFunc("db", strings.ToLower)
+	typ := reflect.TypeOf(E4{})
+	m.Preload(typ)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			m.TypeMap(typ)
+		}
+	})
+}
+
 type E1 struct {
 	A int
 }
@@ -1,6 +1,7 @@
 package sqlx
 
 import (
+    "context"
     "database/sql"
     "errors"
     "github.com/tietang/sqlx/reflectx"
@@ -14,6 +15,7 @@ type Row struct {
     unsafe bool
     rows   *sql.Rows
     Mapper *reflectx.Mapper
+    ctx    context.Context // of the query, passed to AfterScan hooks
 }
 
 // Scan is a fixed implementation of sql.Row.Scan, which does not discard the
@@ -119,10 +121,10 @@ func (r *Row) scanAny(dest interface{}, structOnly bool) error {
     }
 
     if v.Elem().Kind() == reflect.Slice || v.Elem().Kind() == reflect.Map {
-        return fetchRows(r.rows, dest)
+        return fetchRows(r.ctx, r.rows, dest, r.Mapper)
     }
 
-    return fetchRow(r.rows, dest)
+    return fetchRow(r.ctx, r.rows, dest, r.Mapper)
     //
     //base := reflectx.Deref(v.Type())
     //scannable := isScannable(base)
@@ -1,6 +1,7 @@
 package sqlx
 
 import (
+    "context"
     "database/sql"
     "errors"
     "fmt"
@@ -22,12 +23,22 @@ type Rows struct {
     *sql.Rows
     unsafe bool
     Mapper *reflectx.Mapper
-    // these fields cache memory use for a rows during iteration w/ structScan
+    ctx    context.Context // of the query, passed to AfterScan hooks
+    // these fields cache memory use for a rows during iteration w/ structScan;
+    // they describe the current result set and are reset by NextResultSet
     started bool
-    fields  [][]int
+    columns []string
+    fields  map[reflect.Type]*rowsTraversal
     values  []interface{}
 }
 
+// rowsTraversal is how a result set is scanned into a struct type.
+type rowsTraversal struct {
+    fields    [][]int
+    groups    []nullGroup
+    afterScan bool
+}
+
 // SliceScan using this Rows.
 func (r *Rows) SliceScan() ([]interface{}, error) {
     return SliceScan(r)
@@ -38,11 +49,21 @@ func (r *Rows) MapScan(dest map[string]interface{}) error {
     return MapScan(r, dest)
 }
 
+// NextResultSet prepares the next result set for reading, like
+// sql.Rows.NextResultSet.  The traversals cached by StructScan are dropped, as
+// the next result set may have different columns.
+func (r *Rows) NextResultSet() bool {
+    r.started = false
+    r.columns = nil
+    r.fields = nil
+    return r.Rows.NextResultSet()
+}
+
 // StructScan is like sql.Rows.Scan, but scans a single Row into a single Struct.
 // Use this and iterate over Rows manually when the memory load of Select() might be
 // prohibitive.  *Rows.StructScan caches the reflect work of matching up column
-// positions to fields to avoid that overhead per scan, which means it is not safe
-// to run StructScan on the same Rows instance with different struct types.
+// positions to fields per destination type to avoid that overhead per scan, so
+// rows of one result set may be scanned into different struct types.
 func (r *Rows) StructScan(dest interface{}) error {
     v := reflect.ValueOf(dest)
 
@@ -52,30 +73,55 @@ func (r *Rows) StructScan(dest interface{}) error {
 
     v = v.Elem()
 
-    if !r.started {
-        columns, err := r.Columns()
-        if err != nil {
-            return err
-        }
-        m := r.Mapper
-
-        r.fields = m.TraversalsByName(v.Type(), columns)
-        // if we are not unsafe and are missing fields, return an error
-        if f, err := missingFields(r.fields); err != nil && !r.unsafe {
-            return fmt.Errorf("missing destination name %s in %T", columns[f], dest)
-        }
-        r.values = make([]interface{}, len(columns))
-        r.started = true
+    tr, err := r.traversals(v.Type(), dest)
+    if err != nil {
+        return err
     }
 
-    err := fieldsByTraversal(v, r.fields, r.values, true)
+    err = fieldsByTraversal(v, tr.fields, r.values, true)
     if err != nil {
         return err
     }
+    bindNullGroups(tr.groups, r.values)
     // scan into the struct field pointers and append to our results
     err = r.Scan(r.values...)
     if err != nil {
         return err
     }
+    setNullGroups(v, tr.groups, r.values)
+    if tr.afterScan {
+        if err = afterScan(r.ctx, v); err != nil {
+            return err
+        }
+    }
     return r.Err()
 }
+
+// traversals returns the field traversals for scanning the current result set
+// into the type t, computing and caching them on first use.
+func (r *Rows) traversals(t reflect.Type, dest interface{}) (*rowsTraversal, error) {
+    if !r.started {
+        columns, err := r.Columns()
+        if err != nil {
+            return nil, err
+        }
+        r.columns = columns
+        r.fields = make(map[reflect.Type]*rowsTraversal, 1)
+        r.values = make([]interface{}, len(columns))
+        r.started = true
+    }
+
+    if tr, ok := r.fields[t]; ok {
+        return tr, nil
+    }
+
+    fields := r.Mapper.TraversalsByName(t, r.columns)
+    // if we are not unsafe and are missing fields, return an error
+    if f, err := missingFields(fields); err != nil && !r.unsafe {
+        return nil, fmt.Errorf("missing destination name %s in %T", r.columns[f], dest)
+    }
+    tr := &rowsTraversal{afterScan: hasAfterScan(t)}
+    tr.groups, tr.fields = nullGroups(r.Mapper, t, fields)
+    r.fields[t] = tr
+    return tr, nil
+}
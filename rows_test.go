@@ -0,0 +1,80 @@
+package sqlx
+
+import "testing"
+
+func TestRowsStructScan(t *testing.T) {
+	db, err := Open("sqlxfetch", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	type score struct {
+		ID    int64
+		Score float64
+	}
+
+	rows, err := db.Unsafe().Queryx("4,2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	// alternate destination types within one result set
+	var users []fetchFlatUser
+	var scores []score
+	for i := 0; rows.Next(); i++ {
+		if i%2 == 0 {
+			var u fetchFlatUser
+			if err = rows.StructScan(&u); err != nil {
+				t.Fatal(err)
+			}
+			users = append(users, u)
+			continue
+		}
+		var s score
+		if err = rows.StructScan(&s); err != nil {
+			t.Fatal(err)
+		}
+		scores = append(scores, s)
+	}
+	if len(users) != 2 || users[1].ID != 3 || users[1].Email != "name@example.com" {
+		t.Errorf("unexpected users: %#v", users)
+	}
+	if len(scores) != 2 || scores[1].ID != 4 || scores[1].Score != 2 {
+		t.Errorf("unexpected scores: %#v", scores)
+	}
+
+	// the second result set has fewer columns
+	if !rows.NextResultSet() {
+		t.Fatalf("expected a second result set: %v", rows.Err())
+	}
+	scores = scores[:0]
+	for rows.Next() {
+		var s score
+		if err = rows.StructScan(&s); err != nil {
+			t.Fatal(err)
+		}
+		scores = append(scores, s)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores[1].ID != 2 || scores[1].Score != 1 {
+		t.Errorf("unexpected scores: %#v", scores)
+	}
+
+	// without Unsafe, a type missing a column is still an error
+	rows, err = db.Queryx("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var s score
+	if err = rows.StructScan(&s); err == nil {
+		t.Error("expected missing destination name error")
+	}
+}
@@ -0,0 +1,24 @@
+package sqlx
+
+import (
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+)
+
+// softDeleteColumn returns the column and field of the struct type t tagged
+// with the softdelete option, if any.  Rows of such structs are marked deleted
+// by setting the column to the current time instead of being deleted, and the
+// statements generated for them skip rows where it is not NULL; the field
+// must be a *time.Time or sql.NullTime.
+func softDeleteColumn(m *reflectx.Mapper, t reflect.Type) (string, *reflectx.FieldInfo) {
+    t = reflectx.Deref(t)
+    if t.Kind() != reflect.Struct {
+        return "", nil
+    }
+    for name, fi := range m.TypeMap(t).Names {
+        if _, ok := fi.Options["softdelete"]; ok {
+            return name, fi
+        }
+    }
+    return "", nil
+}
@@ -143,6 +143,50 @@ func isUnsafe(i interface{}) bool {
     }
 }
 
+// determine if any of our extensions are unscoped
+func isUnscoped(i interface{}) bool {
+    switch v := i.(type) {
+    case DB:
+        return v.unscoped
+    case *DB:
+        return v.unscoped
+    case Tx:
+        return v.unscoped
+    case *Tx:
+        return v.unscoped
+    default:
+        return false
+    }
+}
+
+// determine if any of our extensions put query args into QueryErrors
+func hasErrorArgs(i interface{}) bool {
+    switch v := i.(type) {
+    case NamedStmt:
+        return v.Stmt.errorArgs
+    case *NamedStmt:
+        return v.Stmt.errorArgs
+    case Stmt:
+        return v.errorArgs
+    case *Stmt:
+        return v.errorArgs
+    case qStmt:
+        return v.errorArgs
+    case *qStmt:
+        return v.errorArgs
+    case DB:
+        return v.errorArgs
+    case *DB:
+        return v.errorArgs
+    case Tx:
+        return v.errorArgs
+    case *Tx:
+        return v.errorArgs
+    default:
+        return false
+    }
+}
+
 func mapperFor(i interface{}) *reflectx.Mapper {
     switch i := i.(type) {
     case DB:
@@ -5,6 +5,7 @@ package sqlx
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -54,11 +55,24 @@ type ExtContext interface {
 func SelectContext(ctx context.Context, q QueryerContext, dest interface{}, query string, args ...interface{}) error {
 	rows, err := q.QueryxContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return queryError("select", q, query, args, err)
 	}
 	// if something happens here, we want to make sure the rows are Closed
 	defer rows.Close()
-	return scanAll(rows, dest, false)
+	return queryError("select", q, query, args, scanAll(rows, dest, false))
+}
+
+// SelectGroupedContext executes a query using the provided Queryer and scans
+// the rows of a one-to-many join into dest, grouping them as SelectGrouped
+// does.
+func SelectGroupedContext(ctx context.Context, q QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return queryError("select", q, query, args, err)
+	}
+	// if something happens here, we want to make sure the rows are Closed
+	defer rows.Close()
+	return queryError("select", q, query, args, scanGrouped(rows, dest))
 }
 
 // PreparexContext prepares a statement.
@@ -70,7 +84,7 @@ func PreparexContext(ctx context.Context, p PreparerContext, query string) (*Stm
 	if err != nil {
 		return nil, err
 	}
-	return &Stmt{Stmt: s, unsafe: isUnsafe(p), Mapper: mapperFor(p)}, err
+	return &Stmt{Stmt: s, query: query, unsafe: isUnsafe(p), errorArgs: hasErrorArgs(p), Mapper: mapperFor(p)}, err
 }
 
 // GetContext does a QueryRow using the provided Queryer, and scans the
@@ -80,7 +94,7 @@ func PreparexContext(ctx context.Context, p PreparerContext, query string) (*Stm
 // An error is returned if the result set is empty.
 func GetContext(ctx context.Context, q QueryerContext, dest interface{}, query string, args ...interface{}) error {
 	r := q.QueryRowxContext(ctx, query, args...)
-	return r.scanAny(dest, false)
+	return queryError("get", q, query, args, r.scanAny(dest, false))
 }
 
 // LoadFileContext exec's every statement in a file (as a single call to Exec).
@@ -112,7 +126,7 @@ func LoadFileContext(ctx context.Context, e ExecerContext, path string) (*sql.Re
 func MustExecContext(ctx context.Context, e ExecerContext, query string, args ...interface{}) sql.Result {
 	res, err := e.ExecContext(ctx, query, args...)
 	if err != nil {
-		panic(err)
+		panic(queryError("exec", e, query, args, err))
 	}
 	return res
 }
@@ -147,6 +161,93 @@ func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, ar
 	return GetContext(ctx, db, dest, query, args...)
 }
 
+// InsertContext inserts dest, a struct, into the table named after its type.
+// The BeforeInsert and Validate hooks of dest are called with ctx first.
+func (db *DB) InsertContext(ctx context.Context, dest interface{}) (sql.Result, error) {
+	name, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapInsert))
+	if err != nil {
+		return nil, err
+	}
+	return db.insertTable(ctx, name, columnNames, columnValues)
+}
+
+// InsertTableContext inserts dest into the table tableName, like InsertContext.
+func (db *DB) InsertTableContext(ctx context.Context, tableName string, dest interface{}) (sql.Result, error) {
+	_, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapInsert))
+	if err != nil {
+		return nil, err
+	}
+	return db.insertTable(ctx, tableName, columnNames, columnValues)
+}
+
+// UpdateContext updates the row of dest, a struct, in the table named after
+// its type, like Update.  The BeforeUpdate and Validate hooks of dest are
+// called with ctx first.
+func (db *DB) UpdateContext(ctx context.Context, dest interface{}) (sql.Result, error) {
+	name, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapUpdate))
+	if err != nil {
+		return nil, err
+	}
+	return db.updateTable(ctx, name, dest, columnNames, columnValues)
+}
+
+// UpdateTableContext updates the row of dest in the table tableName, like
+// UpdateContext.
+func (db *DB) UpdateTableContext(ctx context.Context, tableName string, dest interface{}) (sql.Result, error) {
+	_, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapUpdate))
+	if err != nil {
+		return nil, err
+	}
+	return db.updateTable(ctx, tableName, dest, columnNames, columnValues)
+}
+
+// UpdateChanged updates the row of dest, a pointer to a struct embedding
+// Tracked which was passed to Track, in the table tableName, setting only the
+// columns whose values changed since it was tracked.  The columns of both the
+// struct and its snapshot are those of the DB's Mapper.  The row is found by
+// the key columns, as in UpdateContext, and nothing is executed when no column
+// changed.  The snapshot of dest is refreshed after a successful update.
+func (db *DB) UpdateChanged(ctx context.Context, tableName string, dest interface{}) (sql.Result, error) {
+	// Check for changes before the hooks and timestamps touch the struct.
+	if _, _, changed, err := changedColumns(db.Mapper, dest); err != nil {
+		return nil, err
+	} else if changed == 0 {
+		return driver.RowsAffected(0), nil
+	}
+	if _, _, _, err := MapContext(ctx, dest, db.mapOptions(MapUpdate)); err != nil {
+		return nil, err
+	}
+	columnNames, columnValues, _, err := changedColumns(db.Mapper, dest)
+	if err != nil {
+		return nil, err
+	}
+	res, err := db.updateTable(ctx, tableName, dest, columnNames, columnValues)
+	if err != nil {
+		return res, err
+	}
+	return res, track(reflect.ValueOf(dest).Elem())
+}
+
+// DeleteContext deletes the row of dest, a struct, from the table named after
+// its type, like Delete.
+func (db *DB) DeleteContext(ctx context.Context, dest interface{}) (sql.Result, error) {
+	name, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapAny))
+	if err != nil {
+		return nil, err
+	}
+	return db.deleteTable(ctx, name, dest, columnNames, columnValues)
+}
+
+// DeleteTableContext deletes the row of dest from the table tableName, like
+// DeleteContext.
+func (db *DB) DeleteTableContext(ctx context.Context, tableName string, dest interface{}) (sql.Result, error) {
+	_, columnNames, columnValues, err := MapContext(ctx, dest, db.mapOptions(MapAny))
+	if err != nil {
+		return nil, err
+	}
+	return db.deleteTable(ctx, tableName, dest, columnNames, columnValues)
+}
+
 // PreparexContext returns an sqlx.Stmt instead of a sql.Stmt.
 //
 // The provided context is used for the preparation of the statement, not for
@@ -160,16 +261,17 @@ func (db *DB) PreparexContext(ctx context.Context, query string) (*Stmt, error)
 func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	r, err := db.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, queryError("query", db, query, args, err)
 	}
-	return &Rows{Rows: r, unsafe: db.unsafe, Mapper: db.Mapper}, err
+	return &Rows{Rows: r, ctx: ctx, unsafe: db.unsafe, Mapper: db.Mapper}, err
 }
 
 // QueryRowxContext queries the database and returns an *sqlx.Row.
 // Any placeholder parameters are replaced with supplied args.
 func (db *DB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *Row {
 	rows, err := db.DB.QueryContext(ctx, query, args...)
-	return &Row{rows: rows, err: err, unsafe: db.unsafe, Mapper: db.Mapper}
+	err = queryError("query", db, query, args, err)
+	return &Row{rows: rows, ctx: ctx, err: err, unsafe: db.unsafe, Mapper: db.Mapper}
 }
 
 // MustBeginTx starts a transaction, and panics on error.  Returns an *sqlx.Tx instead
@@ -205,24 +307,28 @@ func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{Tx: tx, driverName: db.driverName, unsafe: db.unsafe, Mapper: db.Mapper}, err
+	return &Tx{Tx: tx, driverName: db.driverName, unsafe: db.unsafe, unscoped: db.unscoped, errorArgs: db.errorArgs, Mapper: db.Mapper}, err
 }
 
 // StmtxContext returns a version of the prepared statement which runs within a
-// transaction. Provided stmt can be either *sql.Stmt or *sqlx.Stmt.
+// transaction. Provided stmt can be either *sql.Stmt or *sqlx.Stmt.  The
+// query and safety behavior of an *sqlx.Stmt are kept;  an *sql.Stmt
+// inherits the safety behavior of the transaction.
 func (tx *Tx) StmtxContext(ctx context.Context, stmt interface{}) *Stmt {
 	var s *sql.Stmt
+	var query string
+	unsafe, errorArgs := tx.unsafe, tx.errorArgs
 	switch v := stmt.(type) {
 	case Stmt:
-		s = v.Stmt
+		s, query, unsafe, errorArgs = v.Stmt, v.query, v.unsafe, v.errorArgs
 	case *Stmt:
-		s = v.Stmt
+		s, query, unsafe, errorArgs = v.Stmt, v.query, v.unsafe, v.errorArgs
 	case *sql.Stmt:
 		s = v
 	default:
 		panic(fmt.Sprintf("non-statement type %v passed to Stmtx", reflect.ValueOf(stmt).Type()))
 	}
-	return &Stmt{Stmt: tx.StmtContext(ctx, s), Mapper: tx.Mapper}
+	return &Stmt{Stmt: tx.StmtContext(ctx, s), query: query, unsafe: unsafe, errorArgs: errorArgs, Mapper: tx.Mapper}
 }
 
 // NamedStmtContext returns a version of the prepared statement which runs
@@ -259,9 +365,9 @@ func (tx *Tx) MustExecContext(ctx context.Context, query string, args ...interfa
 func (tx *Tx) QueryxContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	r, err := tx.Tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, queryError("query", tx, query, args, err)
 	}
-	return &Rows{Rows: r, unsafe: tx.unsafe, Mapper: tx.Mapper}, err
+	return &Rows{Rows: r, ctx: ctx, unsafe: tx.unsafe, Mapper: tx.Mapper}, err
 }
 
 // SelectContext within a transaction and context.
@@ -281,7 +387,8 @@ func (tx *Tx) GetContext(ctx context.Context, dest interface{}, query string, ar
 // Any placeholder parameters are replaced with supplied args.
 func (tx *Tx) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *Row {
 	rows, err := tx.Tx.QueryContext(ctx, query, args...)
-	return &Row{rows: rows, err: err, unsafe: tx.unsafe, Mapper: tx.Mapper}
+	err = queryError("query", tx, query, args, err)
+	return &Row{rows: rows, ctx: ctx, err: err, unsafe: tx.unsafe, Mapper: tx.Mapper}
 }
 
 // NamedExecContext using this Tx.
@@ -293,54 +400,57 @@ func (tx *Tx) NamedExecContext(ctx context.Context, query string, arg interface{
 // SelectContext using the prepared statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error {
-	return SelectContext(ctx, &qStmt{s}, dest, "", args...)
+	return SelectContext(ctx, &qStmt{s}, dest, s.query, args...)
 }
 
 // GetContext using the prepared statement.
 // Any placeholder parameters are replaced with supplied args.
 // An error is returned if the result set is empty.
 func (s *Stmt) GetContext(ctx context.Context, dest interface{}, args ...interface{}) error {
-	return GetContext(ctx, &qStmt{s}, dest, "", args...)
+	return GetContext(ctx, &qStmt{s}, dest, s.query, args...)
 }
 
-// MustExecContext (panic) using this statement.  Note that the query portion of
-// the error output will be blank, as Stmt does not expose its query.
+// MustExecContext (panic) using this statement.  The error includes the query
+// the statement was prepared from.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) MustExecContext(ctx context.Context, args ...interface{}) sql.Result {
-	return MustExecContext(ctx, &qStmt{s}, "", args...)
+	return MustExecContext(ctx, &qStmt{s}, s.query, args...)
 }
 
 // QueryRowxContext using this statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) QueryRowxContext(ctx context.Context, args ...interface{}) *Row {
 	qs := &qStmt{s}
-	return qs.QueryRowxContext(ctx, "", args...)
+	return qs.QueryRowxContext(ctx, s.query, args...)
 }
 
 // QueryxContext using this statement.
 // Any placeholder parameters are replaced with supplied args.
 func (s *Stmt) QueryxContext(ctx context.Context, args ...interface{}) (*Rows, error) {
 	qs := &qStmt{s}
-	return qs.QueryxContext(ctx, "", args...)
+	return qs.QueryxContext(ctx, s.query, args...)
 }
 
 func (q *qStmt) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return q.Stmt.QueryContext(ctx, args...)
+	r, err := q.Stmt.QueryContext(ctx, args...)
+	return r, queryError("query", q, q.Stmt.query, args, err)
 }
 
 func (q *qStmt) QueryxContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	r, err := q.Stmt.QueryContext(ctx, args...)
 	if err != nil {
-		return nil, err
+		return nil, queryError("query", q, q.Stmt.query, args, err)
 	}
-	return &Rows{Rows: r, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}, err
+	return &Rows{Rows: r, ctx: ctx, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}, err
 }
 
 func (q *qStmt) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *Row {
 	rows, err := q.Stmt.QueryContext(ctx, args...)
-	return &Row{rows: rows, err: err, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}
+	err = queryError("query", q, q.Stmt.query, args, err)
+	return &Row{rows: rows, ctx: ctx, err: err, unsafe: q.Stmt.unsafe, Mapper: q.Stmt.Mapper}
 }
 
 func (q *qStmt) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return q.Stmt.ExecContext(ctx, args...)
+	r, err := q.Stmt.ExecContext(ctx, args...)
+	return r, queryError("exec", q, q.Stmt.query, args, err)
 }
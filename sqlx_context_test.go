@@ -16,6 +16,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -1342,3 +1343,432 @@ func TestEmbeddedLiteralsContext(t *testing.T) {
 		}
 	})
 }
+
+func TestPreloadContext(t *testing.T) {
+	type Employee struct {
+		Name        string
+		ID          int64
+		BossID      sql.NullInt64 `db:"boss_id"`
+		Reports     []Employee    `db:"-" rel:"has_many,fk=boss_id,table=employees"`
+		FirstReport *Employee     `db:"-" rel:"has_one,fk=boss_id,table=employees,order=id DESC"`
+		AnyReport   *Employee     `db:"-" rel:"has_one,fk=boss_id,table=employees"`
+	}
+
+	RunWithSchemaContext(context.Background(), defaultSchema, t, func(ctx context.Context, db *DB, t *testing.T) {
+		loadDefaultFixtureContext(ctx, db, t)
+
+		var employees []Employee
+		if err := db.SelectContext(ctx, &employees, "SELECT * FROM employees ORDER BY id"); err != nil {
+			t.Fatal(err)
+		}
+		if err := Preload(ctx, db, &employees, "Reports.Reports", "FirstReport"); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range employees {
+			switch e.Name {
+			case "Peter":
+				if len(e.Reports) != 2 || e.FirstReport == nil {
+					t.Fatalf("expected Peter to have 2 reports, got %#v", e)
+				}
+				for _, r := range e.Reports {
+					if r.BossID.Int64 != e.ID || r.Reports != nil {
+						t.Errorf("unexpected report %#v", r)
+					}
+				}
+				if e.FirstReport.BossID.Int64 != e.ID || e.FirstReport.Name != "Martin" {
+					t.Errorf("unexpected first report %#v", e.FirstReport)
+				}
+			default:
+				if e.Reports != nil || e.FirstReport != nil {
+					t.Errorf("expected %s to have no reports, got %#v", e.Name, e)
+				}
+			}
+		}
+
+		var peter Employee
+		if err := db.GetContext(ctx, &peter, "SELECT * FROM employees WHERE name = 'Peter'"); err != nil {
+			t.Fatal(err)
+		}
+		if err := Preload(ctx, db, &peter, "Reports"); err != nil {
+			t.Fatal(err)
+		}
+		if len(peter.Reports) != 2 {
+			t.Errorf("expected 2 reports, got %#v", peter.Reports)
+		}
+
+		if err := Preload(ctx, db, &peter, "Name"); err == nil {
+			t.Error("expected an error preloading a field without a rel tag")
+		}
+		// a has_one relation without an order must match a single row
+		if err := Preload(ctx, db, &peter, "AnyReport"); err == nil {
+			t.Error("expected an error preloading a has_one relation with 2 rows")
+		}
+
+		// more parents than a single query can bind
+		many := make([]Employee, 2*maxBindParams(db.DriverName())+1)
+		for i := range many {
+			many[i].ID = int64(i + 5000)
+		}
+		many[len(many)-1].ID = peter.ID
+		if err := Preload(ctx, db, &many, "Reports"); err != nil {
+			t.Fatal(err)
+		}
+		if len(many[len(many)-1].Reports) != 2 || many[0].Reports != nil {
+			t.Errorf("expected only the last parent to have reports, got %#v", many[len(many)-1])
+		}
+	})
+}
+
+type hookKey struct{}
+
+type hookPerson struct {
+	FirstName string `db:"first_name"`
+	LastName  string `db:"last_name"`
+	Email     string
+	FullName  string `db:"-"`
+}
+
+func (p *hookPerson) BeforeInsert(ctx context.Context) error {
+	if ctx.Value(hookKey{}) == nil {
+		return errors.New("missing context value")
+	}
+	p.Email = strings.ToLower(p.Email)
+	return nil
+}
+
+func (p *hookPerson) Validate() error {
+	if p.FirstName == "" {
+		return errors.New("first name is required")
+	}
+	return nil
+}
+
+func (p *hookPerson) AfterScan(ctx context.Context) error {
+	if ctx.Value(hookKey{}) == nil {
+		return errors.New("missing context value")
+	}
+	p.FullName = p.FirstName + " " + p.LastName
+	return nil
+}
+
+func TestHooksContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), hookKey{}, true)
+	RunWithSchemaContext(ctx, defaultSchema, t, func(ctx context.Context, db *DB, t *testing.T) {
+		_, err := db.InsertTableContext(ctx, "person", &hookPerson{FirstName: "Ada", LastName: "Lovelace", Email: "ADA@Example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.InsertTableContext(ctx, "person", &hookPerson{LastName: "Nobody"})
+		if err == nil || err.Error() != "first name is required" {
+			t.Errorf("expected Validate to abort the insert, got %v", err)
+		}
+
+		var people []hookPerson
+		err = db.SelectContext(ctx, &people, "SELECT first_name, last_name, email FROM person")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(people) != 1 || people[0].Email != "ada@example.com" || people[0].FullName != "Ada Lovelace" {
+			t.Errorf("unexpected people: %#v", people)
+		}
+
+		var p hookPerson
+		err = db.QueryRowxContext(ctx, "SELECT first_name, last_name, email FROM person").StructScan(&p)
+		if err != nil || p.FullName != "Ada Lovelace" {
+			t.Errorf("expected AfterScan on Row.StructScan, got %#v, %v", p, err)
+		}
+
+		rows, err := db.QueryxContext(ctx, "SELECT first_name, last_name, email FROM person")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			p = hookPerson{}
+			if err = rows.StructScan(&p); err != nil || p.FullName != "Ada Lovelace" {
+				t.Errorf("expected AfterScan on Rows.StructScan, got %#v, %v", p, err)
+			}
+		}
+
+		// without a query context, the hook receives a background context
+		if err = db.Select(&people, "SELECT first_name, last_name, email FROM person"); err == nil {
+			t.Error("expected the AfterScan error to abort Select")
+		}
+
+		// Map runs the hooks on a copy of values
+		_, _, values, err := Map(hookPerson{FirstName: "Grace", Email: "GRACE@x"}, &MapOptions{Op: MapInsert})
+		if err == nil {
+			t.Error("expected the hook to fail without the context value")
+		}
+		_, _, values, err = MapContext(ctx, hookPerson{FirstName: "Grace", Email: "GRACE@x"}, &MapOptions{Op: MapInsert})
+		if err != nil || values[0] != "grace@x" {
+			t.Errorf("expected the hook to lower the email, got %v, %v", values, err)
+		}
+	})
+}
+
+func TestSoftDeleteContext(t *testing.T) {
+	var schema = Schema{
+		create: `
+CREATE TABLE author (id integer, name text);
+CREATE TABLE post (id integer, author_id integer, title text, deleted_at timestamp NULL);`,
+		drop: `
+drop table author;
+drop table post;`,
+	}
+
+	type Post struct {
+		ID        int64      `db:"id,key"`
+		AuthorID  int64      `db:"author_id"`
+		Title     string     `db:"title"`
+		DeletedAt *time.Time `db:"deleted_at,softdelete"`
+	}
+	type Author struct {
+		ID    int64  `db:"id"`
+		Name  string `db:"name"`
+		Posts []Post `db:"-" rel:"has_many,fk=author_id"`
+	}
+
+	RunWithSchemaContext(context.Background(), schema, t, func(ctx context.Context, db *DB, t *testing.T) {
+		db.MustExecContext(ctx, db.Rebind("INSERT INTO author (id, name) VALUES (?, ?)"), 1, "ann")
+		for i := 1; i <= 3; i++ {
+			if _, err := db.InsertContext(ctx, &Post{ID: int64(i), AuthorID: 1, Title: fmt.Sprint("post ", i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		post := Post{ID: 1, AuthorID: 1, Title: "post 1"}
+		if _, err := db.DeleteContext(ctx, &post); err != nil {
+			t.Fatal(err)
+		}
+		if post.DeletedAt == nil {
+			t.Error("expected deleted_at to be set on the struct")
+		}
+		// deleting it again, or deleting a missing row, marks nothing
+		for _, p := range []Post{{ID: 1}, {ID: 99}} {
+			if _, err := db.DeleteContext(ctx, &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.DeletedAt != nil {
+				t.Errorf("expected deleted_at to stay unset without a deleted row, got %v", p.DeletedAt)
+			}
+		}
+		var n int
+		if err := db.QueryRowxContext(ctx, "SELECT count(*) FROM post WHERE deleted_at IS NOT NULL").Scan(&n); err != nil || n != 1 {
+			t.Errorf("expected one row marked deleted, got %d, %v", n, err)
+		}
+
+		// updates skip deleted rows unless unscoped
+		post.Title = "edited"
+		res, err := db.UpdateContext(ctx, &post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Errorf("expected a deleted row not to be updated, got %d rows", n)
+		}
+		res, err = db.Unscoped().UpdateContext(ctx, &post)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			t.Errorf("expected an unscoped update, got %d rows", n)
+		}
+
+		authors := []Author{{ID: 1}}
+		if err = Preload(ctx, db, &authors, "Posts"); err != nil {
+			t.Fatal(err)
+		}
+		if len(authors[0].Posts) != 2 {
+			t.Errorf("expected the deleted post to be skipped, got %#v", authors[0].Posts)
+		}
+		authors[0].Posts = nil
+		if err = Preload(ctx, db.Unscoped(), &authors, "Posts"); err != nil {
+			t.Fatal(err)
+		}
+		if len(authors[0].Posts) != 3 {
+			t.Errorf("expected 3 posts when unscoped, got %#v", authors[0].Posts)
+		}
+
+		if _, err = db.Unscoped().DeleteContext(ctx, &post); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.QueryRowxContext(ctx, "SELECT count(*) FROM post").Scan(&n); err != nil || n != 2 {
+			t.Errorf("expected an unscoped delete to remove the row, got %d rows, %v", n, err)
+		}
+	})
+}
+
+func TestUpdateChangedContext(t *testing.T) {
+	var schema = Schema{
+		create: `
+CREATE TABLE account (id integer, name text, email text, balance integer, note text NULL);`,
+		drop: `
+drop table account;`,
+	}
+
+	type Account struct {
+		Tracked
+		ID      int64   `db:"id,key"`
+		Name    string  `db:"name"`
+		Email   string  `db:"email"`
+		Balance int64   `db:"balance,omitempty"`
+		Note    *string `db:"note"`
+	}
+
+	RunWithSchemaContext(context.Background(), schema, t, func(ctx context.Context, db *DB, t *testing.T) {
+		db.MustExecContext(ctx, db.Rebind("INSERT INTO account (id, name, email, balance, note) VALUES (?, ?, ?, ?, ?)"), 1, "ann", "ann@example.com", 10, "vip")
+
+		var a Account
+		if _, err := db.UpdateChanged(ctx, "account", &a); err != ErrNotTracked {
+			t.Errorf("expected ErrNotTracked, got %v", err)
+		}
+		if err := Track(&struct{ ID int64 }{}); err == nil {
+			t.Error("expected an error tracking a struct without Tracked")
+		}
+
+		if err := db.GetContext(ctx, &a, "SELECT * FROM account WHERE id = 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := Track(&a); err != nil {
+			t.Fatal(err)
+		}
+		defer Untrack(&a)
+
+		res, err := db.UpdateChanged(ctx, "account", &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Errorf("expected no update without changes, got %d rows", n)
+		}
+
+		// a concurrent write to a column which is not changed must survive
+		db.MustExecContext(ctx, "UPDATE account SET email = 'other@example.com'")
+
+		a.Name = "anna"
+		a.Balance = 0
+		a.Note = nil
+		if _, err = db.UpdateChanged(ctx, "account", &a); err != nil {
+			t.Fatal(err)
+		}
+
+		var name, email string
+		var balance int64
+		var note sql.NullString
+		row := db.QueryRowxContext(ctx, "SELECT name, email, balance, note FROM account WHERE id = 1")
+		if err = row.Scan(&name, &email, &balance, &note); err != nil {
+			t.Fatal(err)
+		}
+		if name != "anna" || balance != 0 || note.Valid {
+			t.Errorf("expected the changed columns to be updated, got %q %d %v", name, balance, note)
+		}
+		if email != "other@example.com" {
+			t.Errorf("expected unchanged columns not to be written, got email %q", email)
+		}
+
+		// the snapshot is refreshed after the update
+		res, err = db.UpdateChanged(ctx, "account", &a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Errorf("expected no update after the snapshot was refreshed, got %d rows", n)
+		}
+
+		var all []Account
+		if err = db.SelectContext(ctx, &all, "SELECT * FROM account"); err != nil {
+			t.Fatal(err)
+		}
+		if err = Track(&all); err != nil {
+			t.Fatal(err)
+		}
+		// the snapshots move with the elements when the slice grows
+		all = append(all, make([]Account, cap(all))...)
+		all[0].Email = "ann@example.com"
+		if _, err = db.UpdateChanged(ctx, "account", &all[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.QueryRowxContext(ctx, "SELECT email FROM account WHERE id = 1").Scan(&email); err != nil || email != "ann@example.com" {
+			t.Errorf("expected a tracked slice element to be updated, got %q, %v", email, err)
+		}
+		if err = Untrack(&all[0]); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.UpdateChanged(ctx, "account", &all[0]); err != ErrNotTracked {
+			t.Errorf("expected ErrNotTracked after Untrack, got %v", err)
+		}
+
+		// the handle's mapper names the columns of both sides of the diff
+		type nickAccount struct {
+			Tracked
+			ID   int64 `db:"id,key"`
+			Nick string
+		}
+		mdb := NewDb(db.DB, db.DriverName())
+		mdb.MapperFunc(func(s string) string {
+			if s == "Nick" {
+				return "name"
+			}
+			return strings.ToLower(s)
+		})
+		var u nickAccount
+		if err = mdb.GetContext(ctx, &u, "SELECT id, name FROM account WHERE id = 1"); err != nil {
+			t.Fatal(err)
+		}
+		if err = Track(&u); err != nil {
+			t.Fatal(err)
+		}
+		u.Nick = "ann"
+		if _, err = mdb.UpdateChanged(ctx, "account", &u); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.QueryRowxContext(ctx, "SELECT name FROM account WHERE id = 1").Scan(&name); err != nil || name != "ann" {
+			t.Errorf("expected the name to be updated with a custom mapper, got %q, %v", name, err)
+		}
+	})
+}
+
+func TestColumnWriteControlsContext(t *testing.T) {
+	var schema = Schema{
+		create: `
+CREATE TABLE widget (
+	id integer,
+	name text,
+	status text DEFAULT 'new',
+	stamp text DEFAULT 'db'
+);`,
+		drop: `drop table widget;`,
+	}
+
+	type Widget struct {
+		ID     int64  `db:"id,key"`
+		Name   string `db:"name"`
+		Status string `db:"status,default"`
+		Stamp  string `db:"stamp,readonly"`
+	}
+
+	RunWithSchemaContext(context.Background(), schema, t, func(ctx context.Context, db *DB, t *testing.T) {
+		batch := []Widget{{ID: 1, Stamp: "x"}, {ID: 2, Status: "old"}, {ID: 3}}
+		_, err := db.NamedExecContext(ctx, `INSERT INTO widget (id, name, status, stamp) VALUES (:id, :name, :status, :stamp)`, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var widgets []Widget
+		if err = db.SelectContext(ctx, &widgets, "SELECT * FROM widget ORDER BY id"); err != nil {
+			t.Fatal(err)
+		}
+		var statuses []string
+		for _, w := range widgets {
+			statuses = append(statuses, w.Status+":"+w.Stamp)
+		}
+		if strings.Join(statuses, ",") != "new:db,old:db,new:db" {
+			t.Errorf("expected the batch insert to leave out controlled columns, got %v", statuses)
+		}
+
+		// an insert of only controlled columns has nothing left to write
+		_, err = db.NamedExecContext(ctx, `INSERT INTO widget (status, stamp) VALUES (:status, :stamp)`, []Widget{{}})
+		if err == nil || !strings.Contains(err.Error(), "every column") {
+			t.Errorf("expected an error for an insert without columns, got %v", err)
+		}
+	})
+}
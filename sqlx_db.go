@@ -1,9 +1,11 @@
 package sqlx
 
 import (
+    "context"
     "database/sql"
     "fmt"
     "github.com/tietang/sqlx/reflectx"
+    "reflect"
     "strings"
 )
 
@@ -13,6 +15,8 @@ type DB struct {
     *sql.DB
     driverName string
     unsafe     bool
+    unscoped   bool
+    errorArgs  bool
     Mapper     *reflectx.Mapper
 }
 
@@ -61,7 +65,23 @@ func (db *DB) Rebind(query string) string {
 // sqlx.Stmt and sqlx.Tx which are created from this DB will inherit its
 // safety behavior.
 func (db *DB) Unsafe() *DB {
-    return &DB{DB: db.DB, driverName: db.driverName, unsafe: true, Mapper: db.Mapper}
+    return &DB{DB: db.DB, driverName: db.driverName, unsafe: true, unscoped: db.unscoped, errorArgs: db.errorArgs, Mapper: db.Mapper}
+}
+
+// Unscoped returns a version of DB whose generated statements ignore the
+// softdelete column of structs: Delete removes rows instead of marking them
+// deleted, and Update and Preload also see rows marked deleted.  Queries
+// passed to Get, Select and the like are run as they are, scoped or not.
+func (db *DB) Unscoped() *DB {
+    return &DB{DB: db.DB, driverName: db.driverName, unsafe: db.unsafe, unscoped: true, errorArgs: db.errorArgs, Mapper: db.Mapper}
+}
+
+// ErrorArgs returns a version of DB whose QueryErrors carry the values of the
+// query arguments.  They are left out by default, as they may hold personal
+// data or secrets which should not end up in logs.  sqlx.Stmt and sqlx.Tx
+// which are created from this DB inherit the setting.
+func (db *DB) ErrorArgs() *DB {
+    return &DB{DB: db.DB, driverName: db.driverName, unsafe: db.unsafe, unscoped: db.unscoped, errorArgs: true, Mapper: db.Mapper}
 }
 
 // BindNamed binds a query using the DB driver's bindvar type.
@@ -110,7 +130,7 @@ func (db *DB) Beginx() (*Tx, error) {
     if err != nil {
         return nil, err
     }
-    return &Tx{Tx: tx, driverName: db.driverName, unsafe: db.unsafe, Mapper: db.Mapper}, err
+    return &Tx{Tx: tx, driverName: db.driverName, unsafe: db.unsafe, unscoped: db.unscoped, errorArgs: db.errorArgs, Mapper: db.Mapper}, err
 }
 
 // Queryx queries the database and returns an *sqlx.Rows.
@@ -118,7 +138,7 @@ func (db *DB) Beginx() (*Tx, error) {
 func (db *DB) Queryx(query string, args ...interface{}) (*Rows, error) {
     r, err := db.DB.Query(query, args...)
     if err != nil {
-        return nil, err
+        return nil, queryError("query", db, query, args, err)
     }
     return &Rows{Rows: r, unsafe: db.unsafe, Mapper: db.Mapper}, err
 }
@@ -127,6 +147,7 @@ func (db *DB) Queryx(query string, args ...interface{}) (*Rows, error) {
 // Any placeholder parameters are replaced with supplied args.
 func (db *DB) QueryRowx(query string, args ...interface{}) *Row {
     rows, err := db.DB.Query(query, args...)
+    err = queryError("query", db, query, args, err)
     return &Row{rows: rows, err: err, unsafe: db.unsafe, Mapper: db.Mapper}
 }
 
@@ -146,27 +167,222 @@ func (db *DB) PrepareNamed(query string) (*NamedStmt, error) {
     return prepareNamed(db, query)
 }
 
+// Insert inserts dest, a struct, into the table named after its type.  The
+// BeforeInsert and Validate hooks of dest are called first.
 func (db *DB) Insert(dest interface{}) (sql.Result, error) {
-    name, columnNames, columnValues, err := Map(dest, nil)
-    if err != nil {
-        return nil, err
-    }
-    return db.insertTable(name, columnNames, columnValues)
+    return db.InsertContext(context.Background(), dest)
 }
 
+// InsertTable inserts dest into the table tableName, like Insert.
 func (db *DB) InsertTable(tableName string, dest interface{}) (sql.Result, error) {
-    _, columnNames, columnValues, err := Map(dest, nil)
-    if err != nil {
-        return nil, err
+    return db.InsertTableContext(context.Background(), tableName, dest)
+}
+
+// Update updates the row of dest, a struct, in the table named after its type.
+// The row is identified by the fields of dest tagged with the key option, which
+// are not updated themselves.  The BeforeUpdate and Validate hooks of dest are
+// called first.
+//
+// If dest has an integer field tagged with the version option, the update only
+// applies to the row with the version of dest, and increments it.  When no row
+// matches ErrStaleObject is returned; otherwise the version field of dest is
+// incremented too, so dest must then be a pointer.  The version is written
+// even if it is zero and tagged omitempty.  Rows marked deleted through a
+// softdelete field are not updated unless the DB is Unscoped.
+func (db *DB) Update(dest interface{}) (sql.Result, error) {
+    return db.UpdateContext(context.Background(), dest)
+}
+
+// UpdateTable updates the row of dest in the table tableName, like Update.
+func (db *DB) UpdateTable(tableName string, dest interface{}) (sql.Result, error) {
+    return db.UpdateTableContext(context.Background(), tableName, dest)
+}
+
+// Delete deletes the row of dest, a struct, from the table named after its
+// type.  The row is identified by the fields of dest tagged with the key
+// option.  If dest has a field tagged with the softdelete option, the row is
+// marked deleted by setting it to the current time instead, in the database
+// and in dest, unless the DB is Unscoped.
+func (db *DB) Delete(dest interface{}) (sql.Result, error) {
+    return db.DeleteContext(context.Background(), dest)
+}
+
+// DeleteTable deletes the row of dest from the table tableName, like Delete.
+func (db *DB) DeleteTable(tableName string, dest interface{}) (sql.Result, error) {
+    return db.DeleteTableContext(context.Background(), tableName, dest)
+}
+
+// mapOptions returns the options for mapping a struct for a write of the kind
+// op with this database.
+func (db *DB) mapOptions(op MapOp) *MapOptions {
+    return &MapOptions{Op: op, TimePrecision: timePrecision(db.driverName), Mapper: db.Mapper}
+}
+
+func (db *DB) insertTable(ctx context.Context, tableName string, columnNames []string, columnValues []interface{}) (sql.Result, error) {
+    var columns []string
+    var values []interface{}
+    for i, name := range columnNames {
+        // sqlite has no DEFAULT values, leaving the column out is the same
+        if columnValues[i] == Default && !supportsDefault(db.driverName) {
+            continue
+        }
+        columns = append(columns, name)
+        values = append(values, columnValues[i])
     }
-    return db.insertTable(tableName, columnNames, columnValues)
+    placeholders, args := Placeholders(values)
+    query := db.Rebind(fmt.Sprintf("insert into %s(%s) values(%s)", tableName, strings.Join(columns, ","), strings.Join(placeholders, ",")))
+    res, err := db.ExecContext(ctx, query, args...)
+    return res, queryError("insert", db, query, args, err)
 }
 
-func (db *DB) insertTable(tableName string, columnNames []string, columnValues []interface{}) (sql.Result, error) {
-    names := strings.Join(columnNames, ",")
-    placeholders := strings.Repeat("?,", len(columnNames))
-    placeholders = placeholders[:len(placeholders)-1]
-    query := fmt.Sprintf("insert into %s(%s) values(%s)", tableName, names, placeholders)
-    fmt.Println(query)
-    return MustExec(db, query, columnValues...), nil
+// supportsDefault reports whether the DEFAULT keyword can be used for values
+// with driverName.
+func supportsDefault(driverName string) bool {
+    switch driverName {
+    case "sqlite3", "sqlite":
+        return false
+    }
+    return true
+}
+
+func (db *DB) updateTable(ctx context.Context, tableName string, dest interface{}, columnNames []string, columnValues []interface{}) (sql.Result, error) {
+    keys, versionColumn, version := updateColumns(db.Mapper, dest)
+    if len(keys) == 0 {
+        return nil, fmt.Errorf("sqlx: update requires a field tagged with the key option in %T", dest)
+    }
+    if version != nil && reflect.ValueOf(dest).Kind() != reflect.Ptr {
+        // the version is incremented, dest must be able to keep it
+        return nil, ErrExpectingPointer
+    }
+
+    var set, where []string
+    var setArgs, whereArgs []interface{}
+    var next reflect.Value
+    nkeys := 0
+    for i, name := range columnNames {
+        switch {
+        case keys[name]:
+            nkeys++
+            where = append(where, name+"=?")
+            whereArgs = append(whereArgs, columnValues[i])
+        case version != nil && name == versionColumn:
+            var err error
+            if next, err = nextVersion(columnValues[i]); err != nil {
+                return nil, fmt.Errorf("sqlx: version field %s of %T: %v", version.Field.Name, dest, err)
+            }
+            set = append(set, name+"=?")
+            setArgs = append(setArgs, next.Interface())
+            where = append(where, name+"=?")
+            whereArgs = append(whereArgs, columnValues[i])
+        default:
+            if k, ok := columnValues[i].(Keyword); ok {
+                if k == Default && !supportsDefault(db.driverName) {
+                    return nil, fmt.Errorf("sqlx: %s does not support DEFAULT in updates, column %s of %T", db.driverName, name, dest)
+                }
+                set = append(set, name+"="+string(k))
+                continue
+            }
+            set = append(set, name+"=?")
+            setArgs = append(setArgs, columnValues[i])
+        }
+    }
+    if nkeys < len(keys) {
+        return nil, fmt.Errorf("sqlx: update of %T is missing a key value", dest)
+    }
+    if version != nil && !next.IsValid() {
+        return nil, fmt.Errorf("sqlx: update of %T is missing its version column %s", dest, versionColumn)
+    }
+    if deleted, _ := softDeleteColumn(db.Mapper, reflect.TypeOf(dest)); deleted != "" && !db.unscoped {
+        where = append(where, deleted+" is null")
+    }
+    if len(set) == 0 {
+        return nil, fmt.Errorf("sqlx: update of %T has no columns to set", dest)
+    }
+
+    query := db.Rebind(fmt.Sprintf("update %s set %s where %s", tableName, strings.Join(set, ","), strings.Join(where, " and ")))
+    args := append(setArgs, whereArgs...)
+    res, err := db.ExecContext(ctx, query, args...)
+    if err != nil || !next.IsValid() {
+        return res, queryError("update", db, query, args, err)
+    }
+
+    if n, err := res.RowsAffected(); err == nil && n == 0 {
+        return res, ErrStaleObject
+    }
+    reflectx.FieldByIndexes(reflect.ValueOf(dest), version.Index).Set(next.Convert(version.Field.Type))
+    return res, nil
+}
+
+// updateColumns returns the columns of the fields of the struct dest tagged
+// with the key option, and the column and field tagged with the version
+// option, if any.
+func updateColumns(m *reflectx.Mapper, dest interface{}) (keys map[string]bool, versionColumn string, version *reflectx.FieldInfo) {
+    keys = map[string]bool{}
+    t := reflectx.Deref(reflect.TypeOf(dest))
+    if t.Kind() != reflect.Struct {
+        return keys, "", nil
+    }
+    for name, fi := range m.TypeMap(t).Names {
+        if _, ok := fi.Options["key"]; ok {
+            keys[name] = true
+        }
+        if _, ok := fi.Options["version"]; ok {
+            versionColumn, version = name, fi
+        }
+    }
+    return keys, versionColumn, version
+}
+
+// nextVersion returns the integer version v incremented by one.
+func nextVersion(v interface{}) (reflect.Value, error) {
+    rv := reflect.ValueOf(v)
+    switch rv.Kind() {
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return reflect.ValueOf(rv.Int() + 1), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return reflect.ValueOf(rv.Uint() + 1), nil
+    }
+    return reflect.Value{}, fmt.Errorf("expected an integer, got %T", v)
+}
+
+func (db *DB) deleteTable(ctx context.Context, tableName string, dest interface{}, columnNames []string, columnValues []interface{}) (sql.Result, error) {
+    keys, _, _ := updateColumns(db.Mapper, dest)
+    if len(keys) == 0 {
+        return nil, fmt.Errorf("sqlx: delete requires a field tagged with the key option in %T", dest)
+    }
+
+    var where []string
+    var args []interface{}
+    for i, name := range columnNames {
+        if keys[name] {
+            where = append(where, name+"=?")
+            args = append(args, columnValues[i])
+        }
+    }
+    if len(where) < len(keys) {
+        return nil, fmt.Errorf("sqlx: delete of %T is missing a key value", dest)
+    }
+
+    deleted, fi := softDeleteColumn(db.Mapper, reflect.TypeOf(dest))
+    if deleted == "" || db.unscoped {
+        query := db.Rebind(fmt.Sprintf("delete from %s where %s", tableName, strings.Join(where, " and ")))
+        res, err := db.ExecContext(ctx, query, args...)
+        return res, queryError("delete", db, query, args, err)
+    }
+
+    now := db.mapOptions(MapUpdate).now()
+    query := db.Rebind(fmt.Sprintf("update %s set %s=? where %s and %s is null", tableName, deleted, strings.Join(where, " and "), deleted))
+    args = append([]interface{}{now}, args...)
+    res, err := db.ExecContext(ctx, query, args...)
+    if err != nil {
+        return res, queryError("delete", db, query, args, err)
+    }
+    // only claim the delete on dest if a row was marked
+    if n, err := res.RowsAffected(); err != nil || n == 0 {
+        return res, nil
+    }
+    if v := reflect.ValueOf(dest); v.Kind() == reflect.Ptr {
+        setTime(reflectx.FieldByIndexes(v, fi.Index), now)
+    }
+    return res, nil
 }
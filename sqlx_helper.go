@@ -114,11 +114,11 @@ func scanAll(rows *Rows, dest interface{}, structOnly bool) error {
     }
     direct := reflect.Indirect(value)
 
-    if v.Elem().Kind() == reflect.Slice || v.Elem().Kind() == reflect.Map {
-        return fetchRows(rows.Rows, dest)
+    if direct.Kind() == reflect.Slice || direct.Kind() == reflect.Map {
+        return fetchRows(rows.ctx, rows.Rows, dest, rows.Mapper)
     }
 
-    return fetchRow(rows.Rows, dest)
+    return fetchRow(rows.ctx, rows.Rows, dest, rows.Mapper)
 
     slice, err := baseType(value.Type(), reflect.Slice)
     if err != nil {
@@ -293,7 +293,7 @@ func Preparex(p Preparer, query string) (*Stmt, error) {
     if err != nil {
         return nil, err
     }
-    return &Stmt{Stmt: s, unsafe: isUnsafe(p), Mapper: mapperFor(p)}, err
+    return &Stmt{Stmt: s, query: query, unsafe: isUnsafe(p), errorArgs: hasErrorArgs(p), Mapper: mapperFor(p)}, err
 }
 
 // Select executes a query using the provided Queryer, and StructScans each row
@@ -304,11 +304,11 @@ func Preparex(p Preparer, query string) (*Stmt, error) {
 func Select(q Queryer, dest interface{}, query string, args ...interface{}) error {
     rows, err := q.Queryx(query, args...)
     if err != nil {
-        return err
+        return queryError("select", q, query, args, err)
     }
     // if something happens here, we want to make sure the rows are Closed
     defer rows.Close()
-    return scanAll(rows, dest, false)
+    return queryError("select", q, query, args, scanAll(rows, dest, false))
 }
 
 // Get does a QueryRow using the provided Queryer, and scans the resulting row
@@ -319,7 +319,7 @@ func Select(q Queryer, dest interface{}, query string, args ...interface{}) erro
 func Get(q Queryer, dest interface{}, query string, args ...interface{}) error {
     r := q.QueryRowx(query, args...)
 
-    return r.scanAny(dest, false)
+    return queryError("get", q, query, args, r.scanAny(dest, false))
 }
 
 // LoadFile exec's every statement in a file (as a single call to Exec).
@@ -351,7 +351,7 @@ func LoadFile(e Execer, path string) (*sql.Result, error) {
 func MustExec(e Execer, query string, args ...interface{}) sql.Result {
     res, err := e.Exec(query, args...)
     if err != nil {
-        panic(err)
+        panic(queryError("exec", e, query, args, err))
     }
     return res
 }
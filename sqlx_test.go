@@ -503,6 +503,173 @@ func TestJoinQuery(t *testing.T) {
 	})
 }
 
+func TestJoinQueryPrefix(t *testing.T) {
+	type Employee struct {
+		Name   string
+		ID     int64
+		BossID sql.NullInt64 `db:"boss_id"`
+	}
+
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		loadDefaultFixture(db, t)
+
+		type employeeBoss struct {
+			Employee
+			Boss *Employee `db:"boss,prefix=b_"`
+		}
+
+		var employees []employeeBoss
+		err := db.Select(&employees, `SELECT e.*, b.id b_id, b.name b_name FROM employees e
+			LEFT JOIN employees b ON e.boss_id = b.id ORDER BY e.id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(employees) != 3 {
+			t.Fatalf("expected 3 employees, got %d", len(employees))
+		}
+		for _, em := range employees {
+			if em.Name == "Peter" {
+				if em.Boss != nil {
+					t.Errorf("expected Peter to have no boss, got %#v", em.Boss)
+				}
+				continue
+			}
+			if em.Boss == nil || em.Boss.Name != "Peter" || em.Boss.ID != em.BossID.Int64 {
+				t.Errorf("unexpected boss for %s: %#v", em.Name, em.Boss)
+			}
+		}
+
+		// Rows.StructScan resets the pointer of a reused struct
+		rows, err := db.Queryx(`SELECT e.*, b.name b_name FROM employees e
+			LEFT JOIN employees b ON e.boss_id = b.id ORDER BY e.id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var em employeeBoss
+		var bosses []*Employee
+		for rows.Next() {
+			if err = rows.StructScan(&em); err != nil {
+				t.Fatal(err)
+			}
+			bosses = append(bosses, em.Boss)
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if len(bosses) != 3 || bosses[0] == nil || bosses[0] == bosses[1] || bosses[2] != nil {
+			t.Fatalf("unexpected bosses: %v", bosses)
+		}
+		if bosses[0].Name != "Peter" {
+			t.Errorf("expected boss Peter, got %s", bosses[0].Name)
+		}
+	})
+}
+
+func TestSelectGrouped(t *testing.T) {
+	type Report struct {
+		ID   int64 `db:"id,key"`
+		Name string
+		Subs []*struct {
+			Name string
+		} `db:"subs,many,prefix=s_"`
+	}
+	type Boss struct {
+		ID      int64 `db:"id,key"`
+		Name    string
+		Reports []Report `db:"reports,many"`
+	}
+
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		loadDefaultFixture(db, t)
+
+		var bosses []Boss
+		err := SelectGrouped(db, &bosses, `SELECT b.id, b.name,
+			e.id "reports.id", e.name "reports.name", s.name "reports.s_name"
+			FROM employees b
+			LEFT JOIN employees e ON e.boss_id = b.id
+			LEFT JOIN employees s ON s.boss_id = e.id
+			CROSS JOIN employees x
+			ORDER BY b.id, e.id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bosses) != 3 {
+			t.Fatalf("expected 3 bosses, got %d: %#v", len(bosses), bosses)
+		}
+		for _, b := range bosses[:2] {
+			if len(b.Reports) != 0 {
+				t.Errorf("expected %s to have no reports, got %#v", b.Name, b.Reports)
+			}
+		}
+		peter := bosses[2]
+		if peter.Name != "Peter" || len(peter.Reports) != 2 {
+			t.Fatalf("expected Peter to have 2 reports, got %#v", peter)
+		}
+		if peter.Reports[0].Name != "Joe" || peter.Reports[1].Name != "Martin" {
+			t.Errorf("expected reports in row order, got %#v", peter.Reports)
+		}
+		// reports have no subordinates, and Subs has no key, so the
+		// cross join must not produce empty children
+		if len(peter.Reports[0].Subs) != 0 {
+			t.Errorf("expected no subordinates, got %#v", peter.Reports[0].Subs)
+		}
+
+		var reports []Report
+		err = SelectGrouped(db, &reports, `SELECT b.id, b.name, e.name s_name
+			FROM employees b JOIN employees e ON e.boss_id = b.id`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(reports) != 1 || len(reports[0].Subs) != 2 || reports[0].Subs[1].Name == "" {
+			t.Errorf("unexpected reports: %#v", reports)
+		}
+
+		var places []Place
+		err = SelectGrouped(db, &places, "SELECT * FROM place")
+		if err == nil {
+			t.Error("expected an error grouping into a struct without keys")
+		}
+
+		// columns without a destination are an error unless unsafe
+		query := `SELECT b.id, b.name, b.boss_id, e.name s_name
+			FROM employees b JOIN employees e ON e.boss_id = b.id`
+		err = SelectGrouped(db, &reports, query)
+		if err == nil || !strings.Contains(err.Error(), "missing destination name boss_id") {
+			t.Errorf("expected a missing destination name error, got %v", err)
+		}
+		if err = SelectGrouped(db.Unsafe(), &reports, query); err != nil {
+			t.Error(err)
+		}
+		if len(reports) != 1 || len(reports[0].Subs) != 2 {
+			t.Errorf("unexpected reports: %#v", reports)
+		}
+
+		// many fields are not columns of the parent
+		boss := Boss{ID: 10, Name: "Zed", Reports: []Report{{ID: 11, Name: "Ann"}}}
+		_, columns, _, err := Map(&boss, &MapOptions{Op: MapInsert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(columns, []string{"id", "name"}) {
+			t.Errorf("expected columns [id name], got %v", columns)
+		}
+		if _, err = db.InsertTable("employees", &boss); err != nil {
+			t.Fatal(err)
+		}
+		boss.Name = "Zoe"
+		if _, err = db.UpdateTable("employees", &boss); err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		if err = db.QueryRowx(db.Rebind("SELECT name FROM employees WHERE id = ?"), 10).Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		if name != "Zoe" {
+			t.Errorf("expected the inserted boss to be renamed Zoe, got %q", name)
+		}
+	})
+}
+
 func TestJoinQueryNamedPointerStructs(t *testing.T) {
 	type Employee struct {
 		Name string
@@ -1346,6 +1513,120 @@ func TestRebind(t *testing.T) {
 	}
 }
 
+func TestStmtSQL(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		q := db.Rebind("SELECT * FROM person WHERE first_name = ?")
+		stmt, err := db.Preparex(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if stmt.SQL() != q {
+			t.Errorf("expected SQL %q, got %q", q, stmt.SQL())
+		}
+
+		// the wrong number of args is an error which should mention the query
+		_, err = stmt.Queryx()
+		if err == nil {
+			t.Fatal("expected an error with missing args")
+		}
+		if !strings.Contains(err.Error(), q) {
+			t.Errorf("expected error to contain the query, got %v", err)
+		}
+
+		tx := db.MustBegin()
+		defer tx.Rollback()
+		txs := tx.Stmtx(stmt.Unsafe())
+		if txs.SQL() != q {
+			t.Errorf("expected Stmtx to keep SQL %q, got %q", q, txs.SQL())
+		}
+		if !isUnsafe(txs) {
+			t.Error("expected Stmtx to keep the statement unsafe")
+		}
+	})
+}
+
+func TestQueryError(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		q := db.Rebind("SELECT * FROM nonexistent WHERE id = ?")
+		people := []Person{}
+		err := db.Select(&people, q, "secret")
+		qe, ok := err.(*QueryError)
+		if !ok {
+			t.Fatalf("expected a *QueryError, got %T (%v)", err, err)
+		}
+		if qe.Op != "select" || qe.Query != q || qe.NumArgs != 1 {
+			t.Errorf("unexpected QueryError fields: %#v", qe)
+		}
+		if qe.BindType != BindType(db.DriverName()) {
+			t.Errorf("expected bindtype %d, got %d", BindType(db.DriverName()), qe.BindType)
+		}
+		if qe.Unwrap() == nil || qe.Args != nil {
+			t.Errorf("expected the underlying error and no args, got %#v", qe)
+		}
+		if strings.Contains(qe.Error(), "secret") {
+			t.Errorf("expected args to be redacted from %q", qe.Error())
+		}
+		// the error of the Queryx run by Select is wrapped, not relabeled
+		if inner, ok := qe.Unwrap().(*QueryError); !ok || inner.Op != "query" {
+			t.Errorf("expected a wrapped query error, got %#v", qe.Unwrap())
+		}
+
+		_, err = db.ErrorArgs().NamedExec("INSERT INTO nonexistent (a) VALUES (:a)", map[string]interface{}{"a": "secret"})
+		qe, ok = err.(*QueryError)
+		if !ok {
+			t.Fatalf("expected a *QueryError, got %T (%v)", err, err)
+		}
+		if qe.Op != "named exec" || len(qe.Args) != 1 || qe.NumArgs != 1 {
+			t.Errorf("unexpected QueryError fields: %#v", qe)
+		}
+		if !strings.Contains(qe.Error(), "secret") {
+			t.Errorf("expected args in %q", qe.Error())
+		}
+
+		// sql.ErrNoRows is never wrapped
+		var p Person
+		err = db.QueryRowx(db.Rebind("SELECT * FROM person WHERE first_name = ?"), "nobody").StructScan(&p)
+		if err == nil {
+			t.Error("expected an error scanning an empty result")
+		}
+		if _, ok := err.(*QueryError); ok {
+			t.Errorf("expected no rows error not to be wrapped, got %v", err)
+		}
+	})
+}
+
+func TestBindDriver(t *testing.T) {
+	if BindType("postgres-traced") != UNKNOWN {
+		t.Errorf("expected unregistered driver to have an UNKNOWN bindtype")
+	}
+	BindDriver("postgres-traced", DOLLAR)
+	if BindType("postgres-traced") != DOLLAR {
+		t.Errorf("expected registered driver to have a DOLLAR bindtype")
+	}
+	BindDriver("oracle-positional", COLON)
+	if BindType("oracle-positional") != COLON {
+		t.Errorf("expected registered driver to have a COLON bindtype")
+	}
+
+	q := `INSERT INTO foo (a, b, c) VALUES (?, ?, "foo"), ("Hi", ?, ?)`
+	s := Rebind(BindType("oracle-positional"), q)
+	if s != `INSERT INTO foo (a, b, c) VALUES (:1, :2, "foo"), ("Hi", :3, :4)` {
+		t.Errorf("COLON rebind failed: %s", s)
+	}
+
+	s, names, err := compileNamedQuery([]byte(`SELECT * FROM a WHERE first_name=:name1 AND last_name=:name2`), COLON)
+	if err != nil {
+		t.Error(err)
+	}
+	if s != `SELECT * FROM a WHERE first_name=:1 AND last_name=:2` {
+		t.Errorf("COLON named compile failed: %s", s)
+	}
+	if len(names) != 2 {
+		t.Errorf("expected 2 names, got %#v", names)
+	}
+}
+
 func TestBindMap(t *testing.T) {
 	// Test that it works..
 	q1 := `INSERT INTO foo (a, b, c, d) VALUES (:name, :age, :first, :last)`
@@ -1562,6 +1843,93 @@ func TestIn(t *testing.T) {
 			t.Error("Expected an error, but got nil.")
 		}
 	}
+
+	// lists of tuples, from slices of slices and slices of structs
+	type pair struct {
+		A int
+		B string
+	}
+	type tt struct {
+		q    string
+		args []interface{}
+		r    string
+		c    int
+	}
+	tuples := []tt{
+		{"SELECT * FROM foo WHERE (a, b) IN (?)",
+			[]interface{}{[][]interface{}{{1, "x"}, {2, "y"}}},
+			"SELECT * FROM foo WHERE (a, b) IN ((?, ?), (?, ?))",
+			4},
+		{"SELECT * FROM foo WHERE x = ? AND (a, b) IN (?)",
+			[]interface{}{"foo", []pair{{1, "x"}, {2, "y"}, {3, "z"}}},
+			"SELECT * FROM foo WHERE x = ? AND (a, b) IN ((?, ?), (?, ?), (?, ?))",
+			7},
+	}
+	for _, test := range tuples {
+		q, a, err := In(test.q, test.args...)
+		if err != nil {
+			t.Error(err)
+		}
+		if q != test.r {
+			t.Errorf("\nexpected: `%s`\ngot:      `%s`", test.r, q)
+		}
+		if len(a) != test.c {
+			t.Errorf("Expected %d args, but got %d (%+v)", test.c, len(a), a)
+		}
+	}
+
+	// empty slices are always false when AllowEmpty is set
+	empties := []tt{
+		{"SELECT * FROM foo WHERE x IN (?) AND y = ?",
+			[]interface{}{[]int{}, "bar"},
+			"SELECT * FROM foo WHERE x IN (NULL) AND y = ?",
+			1},
+		{"SELECT * FROM foo WHERE (a, b) IN (?)",
+			[]interface{}{[]pair{}},
+			"SELECT * FROM foo WHERE (a, b) IN ((NULL, NULL))",
+			0},
+	}
+	for _, test := range empties {
+		q, a, err := InWithOptions(&InOptions{AllowEmpty: true}, test.q, test.args...)
+		if err != nil {
+			t.Error(err)
+		}
+		if q != test.r {
+			t.Errorf("\nexpected: `%s`\ngot:      `%s`", test.r, q)
+		}
+		if len(a) != test.c {
+			t.Errorf("Expected %d args, but got %d (%+v)", test.c, len(a), a)
+		}
+	}
+
+	// tuples of different lengths can't be expanded
+	_, _, err := In("SELECT * FROM foo WHERE (a, b) IN (?)", [][]int{{1, 2}, {3}})
+	if err == nil {
+		t.Error("Expected an error, but got nil.")
+	}
+	// nor can an empty list of tuples, whose width is unknown
+	_, _, err = InWithOptions(&InOptions{AllowEmpty: true}, "SELECT * FROM foo WHERE (a, b) IN (?)", [][]int{})
+	if err == nil {
+		t.Error("Expected an error for an empty list of tuples, but got nil.")
+	}
+
+	// named byte slice types are single values, not lists or tuples
+	raw := json.RawMessage(`{"a":1}`)
+	q, a, err := In("SELECT * FROM foo WHERE doc = ? AND x IN (?)", raw, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM foo WHERE doc = ? AND x IN (?, ?)" || len(a) != 3 {
+		t.Errorf("Expected a byte slice to be a single value, got %s %v", q, a)
+	}
+	q, a, err = In("SELECT * FROM foo WHERE doc IN (?)", []json.RawMessage{raw, raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM foo WHERE doc IN (?, ?)" || len(a) != 2 {
+		t.Errorf("Expected byte slices to be expanded as scalars, got %s %v", q, a)
+	}
+
 	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
 		loadDefaultFixture(db, t)
 		//tx.MustExec(tx.Rebind("INSERT INTO place (country, city, telcode) VALUES (?, ?, ?)"), "United States", "New York", "1")
@@ -1811,3 +2179,463 @@ func BenchmarkRebindBuffer(b *testing.B) {
 		rebindBuff(DOLLAR, q2)
 	}
 }
+
+type Stamped struct {
+	ID        int64     `db:"id,key"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at,autocreate"`
+	UpdatedAt time.Time `db:"updated_at,autoupdate"`
+}
+
+func TestTimestamps(t *testing.T) {
+	var schema = Schema{
+		create: `CREATE TABLE stamped (id integer, name text, created_at timestamp, updated_at timestamp);`,
+		drop:   `drop table stamped;`,
+	}
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 678901234, time.FixedZone("X", 3600))
+	defer func(clock func() time.Time) { Clock = clock }(Clock)
+	Clock = func() time.Time { return now }
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		precision := timePrecision(db.DriverName())
+		created := now.UTC().Truncate(precision)
+
+		s := Stamped{ID: 1, Name: "first"}
+		if _, err := db.Insert(&s); err != nil {
+			t.Fatal(err)
+		}
+		if !s.CreatedAt.Equal(created) || !s.UpdatedAt.Equal(created) || s.CreatedAt.Location() != time.UTC {
+			t.Errorf("expected both timestamps to be %v, got %#v", created, s)
+		}
+
+		now = now.Add(time.Hour)
+		s.Name = "second"
+		s.CreatedAt = time.Time{}
+		if _, err := db.Update(&s); err != nil {
+			t.Fatal(err)
+		}
+		if !s.UpdatedAt.Equal(now.UTC().Truncate(precision)) {
+			t.Errorf("expected updated_at to be bumped, got %v", s.UpdatedAt)
+		}
+
+		var got Stamped
+		if err := db.QueryRowx("SELECT * FROM stamped").StructScan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "second" || !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(s.UpdatedAt) {
+			t.Errorf("expected the update to keep created_at, got %#v", got)
+		}
+
+		if _, err := db.Update(&struct{ Name string }{"x"}); err == nil {
+			t.Error("expected an error updating a struct without keys")
+		}
+	})
+
+	// a caller provided created_at is kept, and MapOptions override the clock
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 999, time.UTC) }
+	_, columns, values, err := Map(Stamped{CreatedAt: created}, &MapOptions{Op: MapInsert, Clock: clock, TimePrecision: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range columns {
+		switch c {
+		case "created_at":
+			if values[i] != created {
+				t.Errorf("expected created_at to be kept, got %v", values[i])
+			}
+		case "updated_at":
+			if values[i] != clock().Truncate(time.Second) {
+				t.Errorf("expected updated_at from the clock, got %v", values[i])
+			}
+		}
+	}
+	_, columns, _, _ = Map(&Stamped{}, &MapOptions{Op: MapUpdate})
+	for _, c := range columns {
+		if c == "created_at" {
+			t.Error("expected created_at to be left out of updates")
+		}
+	}
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	var schema = Schema{
+		create: `CREATE TABLE versioned (id integer, name text, version integer);`,
+		drop:   `drop table versioned;`,
+	}
+
+	type Versioned struct {
+		ID      int64  `db:"id,key"`
+		Name    string `db:"name"`
+		Version int32  `db:"version,version"`
+	}
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		if _, err := db.Insert(&Versioned{ID: 1, Name: "a", Version: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		var first, second Versioned
+		for _, v := range []*Versioned{&first, &second} {
+			if err := db.QueryRowx("SELECT * FROM versioned WHERE id = 1").StructScan(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		first.Name = "b"
+		if _, err := db.Update(&first); err != nil {
+			t.Fatal(err)
+		}
+		if first.Version != 2 {
+			t.Errorf("expected the version to be bumped to 2, got %d", first.Version)
+		}
+
+		second.Name = "c"
+		_, err := db.Update(&second)
+		if err != ErrStaleObject {
+			t.Errorf("expected ErrStaleObject, got %v", err)
+		}
+		if second.Version != 1 {
+			t.Errorf("expected a stale version to be left alone, got %d", second.Version)
+		}
+
+		var got Versioned
+		if err = db.QueryRowx("SELECT * FROM versioned WHERE id = 1").StructScan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != first {
+			t.Errorf("expected %#v, got %#v", first, got)
+		}
+
+		// a struct passed by value could not keep the bumped version
+		if _, err = db.Update(first); err != ErrExpectingPointer {
+			t.Errorf("expected ErrExpectingPointer, got %v", err)
+		}
+		if err = db.QueryRowx("SELECT * FROM versioned WHERE id = 1").StructScan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != 2 {
+			t.Errorf("expected the version to stay 2, got %d", got.Version)
+		}
+
+		// a zero version is checked even with omitempty
+		type Sparse struct {
+			ID      int64  `db:"id,key"`
+			Name    string `db:"name"`
+			Version int32  `db:"version,version,omitempty"`
+		}
+		zero := Sparse{ID: 2, Name: "d"}
+		if _, err = db.InsertTable("versioned", &zero); err != nil {
+			t.Fatal(err)
+		}
+		stale := zero
+		zero.Name = "e"
+		if _, err = db.UpdateTable("versioned", &zero); err != nil {
+			t.Fatal(err)
+		}
+		if zero.Version != 1 {
+			t.Errorf("expected the version to be bumped to 1, got %d", zero.Version)
+		}
+		stale.Name = "f"
+		if _, err = db.UpdateTable("versioned", &stale); err != ErrStaleObject {
+			t.Errorf("expected ErrStaleObject for a stale zero version, got %v", err)
+		}
+	})
+}
+
+func TestColumnWriteControls(t *testing.T) {
+	var schema = Schema{
+		create: `
+CREATE TABLE widget (
+	id integer,
+	name text,
+	status text DEFAULT 'new',
+	created_by text DEFAULT '',
+	stamp text DEFAULT 'db',
+	label text DEFAULT 'computed'
+);`,
+		drop: `drop table widget;`,
+	}
+
+	type Widget struct {
+		ID        int64  `db:"id,key"`
+		Name      string `db:"name"`
+		Status    string `db:"status,default"`
+		CreatedBy string `db:"created_by,insertonly"`
+		Stamp     string `db:"stamp,readonly"`
+		Label     string `db:"-,scanonly"`
+	}
+
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		w := Widget{ID: 1, Name: "a", CreatedBy: "ann", Stamp: "x", Label: "y"}
+		if _, err := db.Insert(&w); err != nil {
+			t.Fatal(err)
+		}
+		var got Widget
+		if err := db.Get(&got, "SELECT * FROM widget WHERE id = 1"); err != nil {
+			t.Fatal(err)
+		}
+		want := Widget{ID: 1, Name: "a", Status: "new", CreatedBy: "ann", Stamp: "db", Label: "computed"}
+		if got != want {
+			t.Errorf("expected the database to fill controlled columns, got %#v", got)
+		}
+
+		got.Status, got.CreatedBy, got.Stamp = "done", "bob", "z"
+		if _, err := db.Update(&got); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Get(&got, "SELECT * FROM widget WHERE id = 1"); err != nil {
+			t.Fatal(err)
+		}
+		want.Status = "done"
+		if got != want {
+			t.Errorf("expected insertonly and readonly columns to be left alone, got %#v", got)
+		}
+
+		_, columns, _, err := Map(&got, &MapOptions{Op: MapUpdate, IncludeReadOnly: true, IncludeInsertOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(columns, ",") != "created_by,id,label,name,stamp,status" {
+			t.Errorf("expected the overrides to map every column, got %v", columns)
+		}
+		_, columns, _, err = Map(&Widget{ID: 2}, &MapOptions{Op: MapInsert, IncludeDefault: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(columns, ",") != "created_by,id,name,status" {
+			t.Errorf("expected IncludeDefault to map zero default columns, got %v", columns)
+		}
+
+		batch := []Widget{{ID: 2, Stamp: "x"}, {ID: 3}, {ID: 4, Status: "old"}, {ID: 5}}
+		_, err = db.NamedExec(`INSERT INTO widget (id, name, status, stamp) VALUES (:id, :name, :status, :stamp)`, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var widgets []Widget
+		if err = db.Select(&widgets, "SELECT * FROM widget WHERE id > 1 ORDER BY id"); err != nil {
+			t.Fatal(err)
+		}
+		var statuses []string
+		for _, w := range widgets {
+			statuses = append(statuses, w.Status+":"+w.Stamp)
+		}
+		if strings.Join(statuses, ",") != "new:db,new:db,old:db,new:db" {
+			t.Errorf("expected the batch insert to leave out controlled columns, got %v", statuses)
+		}
+	})
+}
+
+func TestMapNullAndDefault(t *testing.T) {
+	type Gadget struct {
+		ID    int64  `db:"id,key"`
+		Name  string `db:"name,omitempty"`
+		Score int    `db:"score,omitempty"`
+		Count *int   `db:"count,omitempty"`
+	}
+	mapped := func(options *MapOptions) map[string]interface{} {
+		_, columns, values, err := Map(&Gadget{ID: 1}, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := map[string]interface{}{}
+		for i, c := range columns {
+			m[c] = values[i]
+		}
+		return m
+	}
+
+	m := mapped(&MapOptions{IncludeNil: true})
+	if v, ok := m["count"]; !ok || v != nil {
+		t.Errorf("expected a nil pointer to be mapped as NULL, got %#v", v)
+	}
+	if _, ok := m["score"]; ok {
+		t.Errorf("expected a zero omitempty field to be left out, got %v", m)
+	}
+
+	m = mapped(&MapOptions{IncludeZeroed: true})
+	if m["score"] != 0 || m["name"] != "" {
+		t.Errorf("expected zero omitempty fields to be mapped as their zero value, got %#v", m)
+	}
+
+	m = mapped(&MapOptions{IncludeZeroed: true, ZeroAsDefault: true})
+	if m["score"] != Default || m["name"] != Default || m["id"] != int64(1) {
+		t.Errorf("expected zero omitempty fields to be mapped as DEFAULT, got %#v", m)
+	}
+
+	if _, _, _, err := Map(&Gadget{ID: 1}, &MapOptions{IncludeZeroed: true, Strict: true}); err == nil {
+		t.Error("expected an error mapping a zero omitempty field in strict mode")
+	}
+	if _, _, _, err := Map(&Gadget{ID: 1, Name: "a", Score: 1}, &MapOptions{Strict: true}); err != nil {
+		t.Errorf("expected no error in strict mode without zero fields, got %v", err)
+	}
+
+	var schema = Schema{
+		create: `CREATE TABLE gadget (id integer, name text DEFAULT 'unnamed', score integer);`,
+		drop:   `drop table gadget;`,
+	}
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		row := map[string]interface{}{"id": 1, "name": Default, "score": nil}
+		if _, err := db.InsertTable("gadget", row); err != nil {
+			t.Fatal(err)
+		}
+		var name string
+		var score sql.NullInt64
+		if err := db.QueryRowx("SELECT name, score FROM gadget WHERE id = 1").Scan(&name, &score); err != nil {
+			t.Fatal(err)
+		}
+		if name != "unnamed" || score.Valid {
+			t.Errorf("expected the default name and a NULL score, got %q, %v", name, score)
+		}
+
+		// values of Map used directly must render keywords, not bind them
+		_, columns, values, err := Map(&Gadget{ID: 2}, &MapOptions{IncludeZeroed: true, ZeroAsDefault: true, Exclude: []string{"count"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := "INSERT INTO gadget (" + strings.Join(columns, ", ") + ") VALUES (?, ?, ?)"
+		if _, err = db.Exec(db.Rebind(query), values...); err == nil {
+			t.Error("expected an error binding the DEFAULT keyword")
+		}
+		placeholders, args := Placeholders(values)
+		if strings.Join(placeholders, ",") != "?,DEFAULT,DEFAULT" || len(args) != 1 || args[0] != int64(2) {
+			t.Errorf("unexpected placeholders %v and args %v", placeholders, args)
+		}
+		if supportsDefault(db.DriverName()) {
+			query = "INSERT INTO gadget (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+			if _, err = db.Exec(db.Rebind(query), args...); err != nil {
+				t.Fatal(err)
+			}
+			if err = db.QueryRowx(db.Rebind("SELECT name FROM gadget WHERE id = ?"), 2).Scan(&name); err != nil || name != "unnamed" {
+				t.Errorf("expected the default name, got %q, %v", name, err)
+			}
+		}
+	})
+}
+
+func TestMapOrder(t *testing.T) {
+	type Ordered struct {
+		Zeta  string `db:"zeta"`
+		Alpha int    `db:"alpha"`
+		Mid   bool   `db:"mid"`
+	}
+	item := &Ordered{Zeta: "z", Alpha: 1, Mid: true}
+	row := map[string]interface{}{"zeta": "z", "alpha": 1, "mid": true}
+
+	tests := []struct {
+		options *MapOptions
+		want    string
+	}{
+		{nil, "alpha,mid,zeta"},
+		{&MapOptions{Order: OrderStruct}, "zeta,alpha,mid"},
+		{&MapOptions{Order: OrderStruct, Exclude: []string{"alpha"}}, "zeta,mid"},
+		{&MapOptions{Order: OrderColumns, Columns: []string{"mid", "zeta"}}, "mid,zeta"},
+		{&MapOptions{Columns: []string{"mid", "zeta"}}, "mid,zeta"},
+		{&MapOptions{Columns: []string{"zeta", "alpha"}, Order: OrderColumns}, "zeta,alpha"},
+	}
+	for _, test := range tests {
+		_, columns, values, err := Map(item, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(columns, ","); got != test.want {
+			t.Errorf("expected columns %s for %+v, got %s", test.want, test.options, got)
+		}
+		for i, column := range columns {
+			if values[i] != row[column] {
+				t.Errorf("expected %v for column %s, got %v", row[column], column, values[i])
+			}
+		}
+		if test.options != nil && test.options.Order == OrderStruct {
+			continue
+		}
+		_, columns, _, err = Map(row, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(columns, ","); got != test.want {
+			t.Errorf("expected map columns %s for %+v, got %s", test.want, test.options, got)
+		}
+	}
+}
+
+func TestMapEmbedded(t *testing.T) {
+	type Base struct {
+		ID      int64     `db:"id,key"`
+		Created time.Time `db:"created"`
+	}
+	type Address struct {
+		City string `db:"city"`
+	}
+	type Shop struct {
+		Base
+		Name    string  `db:"name"`
+		Address Address `db:"address,prefix=addr_"`
+		Billing Address `db:"billing"`
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	shop := &Shop{Base: Base{ID: 1, Created: created}, Name: "a", Address: Address{"x"}, Billing: Address{"y"}}
+	_, columns, values, err := Map(shop, &MapOptions{Order: OrderStruct})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(columns, ","); got != "name,id,created,addr_city,billing.city" {
+		t.Errorf("expected embedded and nested structs to be flattened, got %s", got)
+	}
+	if len(values) != 5 || values[2] != created || values[3] != "x" || values[4] != "y" {
+		t.Errorf("unexpected values %#v", values)
+	}
+	// a nested struct without a prefix has no column it can be written to
+	for _, op := range []MapOp{MapInsert, MapUpdate} {
+		_, _, _, err = Map(shop, &MapOptions{Op: op})
+		if err == nil || !strings.Contains(err.Error(), "billing.city") {
+			t.Errorf("expected an error writing a nested struct, got %v", err)
+		}
+	}
+
+	upper := reflectx.NewMapperFunc("db", strings.ToUpper)
+	type Plain struct {
+		ID   int
+		Name string
+	}
+	_, columns, _, err = Map(&Plain{}, &MapOptions{Mapper: upper})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(columns, ","); got != "ID,NAME" {
+		t.Errorf("expected the columns of the given Mapper, got %s", got)
+	}
+
+	type Other struct {
+		Name string `db:"name"`
+	}
+	type Ambiguous struct {
+		Other
+		Plain `db:""`
+		Name  string `db:"name"`
+	}
+	if _, _, _, err = Map(&Ambiguous{}, nil); err == nil || !strings.Contains(err.Error(), "ambiguous column name") {
+		t.Errorf("expected an ambiguous column error, got %v", err)
+	}
+
+	var schema = Schema{
+		create: `CREATE TABLE plain (x_id integer, x_name text);`,
+		drop:   `drop table plain;`,
+	}
+	RunWithSchema(schema, t, func(db *DB, t *testing.T) {
+		prefixed := NewDb(db.DB, db.DriverName())
+		prefixed.MapperFunc(func(s string) string { return "x_" + strings.ToLower(s) })
+		if _, err := prefixed.InsertTable("plain", &Plain{ID: 1, Name: "a"}); err != nil {
+			t.Fatal(err)
+		}
+		var p Plain
+		if err := prefixed.Get(&p, "SELECT * FROM plain"); err != nil {
+			t.Fatal(err)
+		}
+		if p.ID != 1 || p.Name != "a" {
+			t.Errorf("expected the handle's mapper to be used for inserts, got %#v", p)
+		}
+	})
+}
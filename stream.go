@@ -0,0 +1,74 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlx
+
+import (
+    "context"
+    "errors"
+)
+
+// SelectEach executes a query using the provided QueryerContext and calls fn
+// for every row, without materializing the result set.  Each row is scanned
+// into the same T, so fn must copy anything it wants to keep after it returns.
+// Struct rows are scanned with Rows.StructScan, which matches columns to fields
+// only once for the whole result set.  Iteration stops at the first error
+// returned by fn, which is returned by SelectEach.  The rows are always closed.
+// Any placeholder parameters are replaced with supplied args.
+func SelectEach[T any](ctx context.Context, q QueryerContext, query string, args []interface{}, fn func(*T) error) error {
+    rows, err := q.QueryxContext(ctx, query, args...)
+    if err != nil {
+        return queryError("select", q, query, args, err)
+    }
+    defer rows.Close()
+
+    var v T
+    scannable := newTypedScanner[T]().scannable
+    for rows.Next() {
+        if scannable {
+            err = rows.Scan(&v)
+        } else {
+            err = rows.StructScan(&v)
+        }
+        if err != nil {
+            return queryError("select", q, query, args, err)
+        }
+        if err = fn(&v); err != nil {
+            return err
+        }
+    }
+    return queryError("select", q, query, args, rows.Err())
+}
+
+// errStreamClosed stops SelectChan when its context is done.
+var errStreamClosed = errors.New("sqlx: stream closed")
+
+// SelectChan executes a query using the provided QueryerContext and sends every
+// row, scanned into a T, on the returned channel, which is closed after the last
+// row.  The error channel then receives the error which ended the query, if any,
+// and is closed.  Cancel ctx to stop early;  otherwise the rows channel must be
+// drained for the query to finish and its rows to be closed.
+// Any placeholder parameters are replaced with supplied args.
+func SelectChan[T any](ctx context.Context, q QueryerContext, query string, args ...interface{}) (<-chan T, <-chan error) {
+    out := make(chan T)
+    errc := make(chan error, 1)
+    go func() {
+        defer close(errc)
+        defer close(out)
+        err := SelectEach(ctx, q, query, args, func(v *T) error {
+            select {
+            case out <- *v:
+                return nil
+            case <-ctx.Done():
+                return errStreamClosed
+            }
+        })
+        if err == errStreamClosed {
+            err = ctx.Err()
+        }
+        if err != nil {
+            errc <- err
+        }
+    }()
+    return out, errc
+}
@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package sqlx
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSelectEach(t *testing.T) {
+	RunWithSchema(defaultSchema, t, func(db *DB, t *testing.T) {
+		loadDefaultFixture(db, t)
+		ctx := context.Background()
+
+		var countries []string
+		var last *Place
+		err := SelectEach(ctx, db, "SELECT * FROM place ORDER BY telcode", nil, func(p *Place) error {
+			if last != nil && last != p {
+				t.Error("expected the same struct to be reused for every row")
+			}
+			last = p
+			countries = append(countries, p.Country)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(countries) != 3 || countries[0] != "United States" {
+			t.Errorf("unexpected countries: %v", countries)
+		}
+
+		// an error from the callback stops iteration and is returned
+		stop := errors.New("stop")
+		n := 0
+		err = SelectEach(ctx, db, "SELECT telcode FROM place", nil, func(code *int) error {
+			n++
+			return stop
+		})
+		if err != stop || n != 1 {
+			t.Errorf("expected to stop after one row with %v, got %d rows and %v", stop, n, err)
+		}
+
+		rows, errc := SelectChan[Place](ctx, db, "SELECT * FROM place ORDER BY telcode")
+		var places []Place
+		for p := range rows {
+			places = append(places, p)
+		}
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+		if len(places) != 3 || places[0].Country == places[1].Country {
+			t.Errorf("unexpected places: %#v", places)
+		}
+
+		// cancelling the context stops the stream without draining it
+		cctx, cancel := context.WithCancel(ctx)
+		rows, errc = SelectChan[Place](cctx, db, "SELECT * FROM place ORDER BY telcode")
+		<-rows
+		cancel()
+		if err := <-errc; err == nil {
+			t.Error("expected an error from a cancelled stream")
+		}
+	})
+}
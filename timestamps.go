@@ -0,0 +1,92 @@
+package sqlx
+
+import (
+    "database/sql"
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+    "time"
+)
+
+// Clock returns the current time written to the autocreate and autoupdate
+// columns of mapped structs, unless MapOptions.Clock is set.  Tests may
+// replace it to get predictable timestamps.
+var Clock = time.Now
+
+var (
+    timeType     = reflect.TypeOf(time.Time{})
+    nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// timePrecision is the precision to which the timestamps written with a driver
+// are truncated, so that the struct holds the value the database stores.
+func timePrecision(driverName string) time.Duration {
+    switch driverName {
+    case "mysql", "nrmysql":
+        // DATETIME and TIMESTAMP columns have no fractional seconds by default
+        return time.Second
+    case "sqlserver", "mssql", "azuresql":
+        return 100 * time.Nanosecond
+    }
+    return time.Microsecond
+}
+
+// now returns the time to write to the timestamp columns of an item.
+func (o *MapOptions) now() time.Time {
+    clock := o.Clock
+    if clock == nil {
+        clock = Clock
+    }
+    now := clock().UTC()
+    if o.TimePrecision > 0 {
+        now = now.Truncate(o.TimePrecision)
+    }
+    return now
+}
+
+// touchTimestamps fills the timestamp fields of the addressable struct v for a
+// write of the kind op: fields tagged autocreate are set on insert if they are
+// zero, and fields tagged autoupdate are set on every insert and update.
+func touchTimestamps(v reflect.Value, fields []*reflectx.FieldInfo, options *MapOptions) {
+    if options.Op == MapAny {
+        return
+    }
+    var now time.Time
+    for _, fi := range fields {
+        _, autoCreate := fi.Options["autocreate"]
+        _, autoUpdate := fi.Options["autoupdate"]
+        if !autoUpdate && !(autoCreate && options.Op == MapInsert) {
+            continue
+        }
+        f := reflectx.FieldByIndexes(v, fi.Index)
+        if !autoUpdate && !isZeroTime(f) {
+            continue
+        }
+        if now.IsZero() {
+            now = options.now()
+        }
+        setTime(f, now)
+    }
+}
+
+func isZeroTime(f reflect.Value) bool {
+    switch f.Type() {
+    case timeType:
+        return f.Interface().(time.Time).IsZero()
+    case nullTimeType:
+        return !f.Interface().(sql.NullTime).Valid
+    case reflect.PtrTo(timeType):
+        return f.IsNil() || f.Elem().Interface().(time.Time).IsZero()
+    }
+    return false
+}
+
+func setTime(f reflect.Value, now time.Time) {
+    switch f.Type() {
+    case timeType:
+        f.Set(reflect.ValueOf(now))
+    case nullTimeType:
+        f.Set(reflect.ValueOf(sql.NullTime{Time: now, Valid: true}))
+    case reflect.PtrTo(timeType):
+        f.Set(reflect.ValueOf(&now))
+    }
+}
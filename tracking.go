@@ -0,0 +1,237 @@
+package sqlx
+
+import (
+    "fmt"
+    "github.com/tietang/sqlx/reflectx"
+    "reflect"
+)
+
+// Tracked is embedded in structs whose changes are tracked for
+// DB.UpdateChanged:
+//
+//     type Account struct {
+//         sqlx.Tracked
+//         ID   int64  `db:"id,key"`
+//         Name string `db:"name"`
+//     }
+//
+// Track stores a copy of the struct in it, so the snapshot moves with the
+// struct when it is copied, eg. by a growing slice, and is freed with it.
+// Copies of a tracked struct share its snapshot until one of them is tracked
+// again.
+type Tracked struct {
+    // snapshot is a copy of the struct as it was last tracked, with the
+    // pointers and byte slices it references copied as well.
+    snapshot reflect.Value
+}
+
+func (t *Tracked) tracked() *Tracked {
+    return t
+}
+
+// tracker is implemented by pointers to structs embedding Tracked.
+type tracker interface {
+    tracked() *Tracked
+}
+
+// nilValue stands for a nil pointer when comparing with a snapshot.
+type nilValue struct{}
+
+// Track records the current values of dest, a pointer to a struct embedding
+// Tracked or to a slice of them, so that DB.UpdateChanged can later update
+// only the columns which changed.
+func Track(dest interface{}) error {
+    return eachTracked(dest, track)
+}
+
+// Untrack drops the snapshots of dest, a pointer to a struct embedding
+// Tracked or to a slice of them.
+func Untrack(dest interface{}) error {
+    return eachTracked(dest, func(v reflect.Value) error {
+        t, err := trackedOf(v)
+        if err == nil {
+            t.snapshot = reflect.Value{}
+        }
+        return err
+    })
+}
+
+// eachTracked calls fn with every addressable struct of dest.
+func eachTracked(dest interface{}, fn func(reflect.Value) error) error {
+    v := reflect.ValueOf(dest)
+    if v.Kind() != reflect.Ptr || v.IsNil() {
+        return ErrExpectingPointer
+    }
+    v = v.Elem()
+    switch {
+    case v.Kind() == reflect.Slice:
+        for i := 0; i < v.Len(); i++ {
+            item := v.Index(i)
+            if item.Kind() == reflect.Ptr {
+                if item.IsNil() {
+                    continue
+                }
+                item = item.Elem()
+            }
+            if item.Kind() != reflect.Struct {
+                return ErrExpectingSliceMapStruct
+            }
+            if err := fn(item); err != nil {
+                return err
+            }
+        }
+    case v.Kind() == reflect.Struct:
+        return fn(v)
+    default:
+        return ErrExpectingPointerToEitherMapOrStruct
+    }
+    return nil
+}
+
+// trackedOf returns the Tracked embedded in the addressable struct v.
+func trackedOf(v reflect.Value) (*Tracked, error) {
+    t, ok := v.Addr().Interface().(tracker)
+    if !ok {
+        return nil, fmt.Errorf("sqlx: %s does not embed sqlx.Tracked", v.Type())
+    }
+    return t.tracked(), nil
+}
+
+// track stores a copy of the addressable struct v in its Tracked field.
+func track(v reflect.Value) error {
+    t, err := trackedOf(v)
+    if err != nil {
+        return err
+    }
+    c := reflect.New(v.Type())
+    c.Elem().Set(v)
+    // the copy must not hold on to the previous snapshot
+    c.Interface().(tracker).tracked().snapshot = reflect.Value{}
+    deepen(c.Elem(), map[uintptr]bool{})
+    t.snapshot = c.Elem()
+    return nil
+}
+
+// deepen replaces the pointers and byte slices reachable through exported
+// fields of the copied value v by copies of their own, so that changes made
+// in place to the original are detected.  seen guards against cycles.
+func deepen(v reflect.Value, seen map[uintptr]bool) {
+    switch v.Kind() {
+    case reflect.Struct:
+        for i := 0; i < v.NumField(); i++ {
+            if f := v.Field(i); f.CanSet() {
+                deepen(f, seen)
+            }
+        }
+    case reflect.Ptr:
+        if v.IsNil() || seen[v.Pointer()] {
+            return
+        }
+        seen[v.Pointer()] = true
+        c := reflect.New(v.Type().Elem())
+        c.Elem().Set(v.Elem())
+        deepen(c.Elem(), seen)
+        v.Set(c)
+    case reflect.Slice:
+        if v.IsNil() || v.Type().Elem().Kind() != reflect.Uint8 {
+            return
+        }
+        c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+        reflect.Copy(c, v)
+        v.Set(c)
+    }
+}
+
+// fieldByIndex returns the field of v at index, or the zero Value if a nil
+// pointer is on its path.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+    for _, i := range index {
+        if v.Kind() == reflect.Ptr {
+            if v.IsNil() {
+                return reflect.Value{}
+            }
+            v = v.Elem()
+        }
+        v = v.Field(i)
+    }
+    return v
+}
+
+// snapshotValue returns the value of a field for comparison, dereferencing
+// pointers.
+func snapshotValue(f reflect.Value) interface{} {
+    if !f.IsValid() {
+        return nilValue{}
+    }
+    for f.Kind() == reflect.Ptr {
+        if f.IsNil() {
+            return nilValue{}
+        }
+        f = f.Elem()
+    }
+    if f.Kind() == reflect.Slice && f.IsNil() {
+        return nilValue{}
+    }
+    return f.Interface()
+}
+
+// changedColumns returns the key and version columns of the struct dest and
+// the columns whose values differ from its snapshot, with their values, and
+// the number of changed columns.  Both the struct and its snapshot are read
+// with the fields of m.
+func changedColumns(m *reflectx.Mapper, dest interface{}) ([]string, []interface{}, int, error) {
+    v := reflect.ValueOf(dest)
+    if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+        return nil, nil, 0, ErrExpectingPointer
+    }
+    v = v.Elem()
+    t, err := trackedOf(v)
+    if err != nil {
+        return nil, nil, 0, err
+    }
+    snap := t.snapshot
+    if !snap.IsValid() {
+        return nil, nil, 0, ErrNotTracked
+    }
+
+    var columns []string
+    var values []interface{}
+    changed := 0
+    options := &MapOptions{Op: MapUpdate}
+    fields, err := structFields(m.TypeMap(v.Type()))
+    if err != nil {
+        return nil, nil, 0, fmt.Errorf("sqlx: %v in %s", err, v.Type())
+    }
+    if err = writableColumns(fields, v.Type()); err != nil {
+        return nil, nil, 0, err
+    }
+    for _, fi := range fields {
+        name := fi.Column()
+        if _, autoCreate := fi.Options["autocreate"]; autoCreate || !writable(fi, options) {
+            continue
+        }
+        _, key := fi.Options["key"]
+        _, version := fi.Options["version"]
+        f := fieldByIndex(v, fi.Index)
+        if !key && (!f.IsValid() || isZeroValue(f, fi)) && defaulted(fi, options) {
+            continue
+        }
+        old := fieldByIndex(snap, fi.Index)
+        if !reflect.DeepEqual(snapshotValue(old), snapshotValue(f)) {
+            changed++
+        } else if !key && !version {
+            continue
+        }
+
+        var value interface{}
+        if f.IsValid() && (f.Kind() != reflect.Ptr || !f.IsNil()) {
+            var err error
+            if value, err = marshal(f.Interface()); err != nil {
+                return nil, nil, 0, err
+            }
+        }
+        columns = append(columns, name)
+        values = append(values, value)
+    }
+    return columns, values, changed, nil
+}
@@ -12,6 +12,8 @@ type Tx struct {
     *sql.Tx
     driverName string
     unsafe     bool
+    unscoped   bool
+    errorArgs  bool
     Mapper     *reflectx.Mapper
 }
 
@@ -28,7 +30,21 @@ func (tx *Tx) Rebind(query string) string {
 // Unsafe returns a version of Tx which will silently succeed to scan when
 // columns in the SQL result have no fields in the destination struct.
 func (tx *Tx) Unsafe() *Tx {
-    return &Tx{Tx: tx.Tx, driverName: tx.driverName, unsafe: true, Mapper: tx.Mapper}
+    return &Tx{Tx: tx.Tx, driverName: tx.driverName, unsafe: true, unscoped: tx.unscoped, errorArgs: tx.errorArgs, Mapper: tx.Mapper}
+}
+
+// Unscoped returns a version of Tx whose generated statements ignore the
+// softdelete column of structs, like DB.Unscoped.  Tx has no Update or
+// Delete, so this only affects Preload;  queries passed to Get, Select and
+// the like are run as they are.
+func (tx *Tx) Unscoped() *Tx {
+    return &Tx{Tx: tx.Tx, driverName: tx.driverName, unsafe: tx.unsafe, unscoped: true, errorArgs: tx.errorArgs, Mapper: tx.Mapper}
+}
+
+// ErrorArgs returns a version of Tx whose QueryErrors carry the values of the
+// query arguments, like DB.ErrorArgs.
+func (tx *Tx) ErrorArgs() *Tx {
+    return &Tx{Tx: tx.Tx, driverName: tx.driverName, unsafe: tx.unsafe, unscoped: tx.unscoped, errorArgs: true, Mapper: tx.Mapper}
 }
 
 // BindNamed binds a query within a transaction's bindvar type.
@@ -59,7 +75,7 @@ func (tx *Tx) Select(dest interface{}, query string, args ...interface{}) error
 func (tx *Tx) Queryx(query string, args ...interface{}) (*Rows, error) {
     r, err := tx.Tx.Query(query, args...)
     if err != nil {
-        return nil, err
+        return nil, queryError("query", tx, query, args, err)
     }
     return &Rows{Rows: r, unsafe: tx.unsafe, Mapper: tx.Mapper}, err
 }
@@ -68,6 +84,7 @@ func (tx *Tx) Queryx(query string, args ...interface{}) (*Rows, error) {
 // Any placeholder parameters are replaced with supplied args.
 func (tx *Tx) QueryRowx(query string, args ...interface{}) *Row {
     rows, err := tx.Tx.Query(query, args...)
+    err = queryError("query", tx, query, args, err)
     return &Row{rows: rows, err: err, unsafe: tx.unsafe, Mapper: tx.Mapper}
 }
 
@@ -90,20 +107,23 @@ func (tx *Tx) Preparex(query string) (*Stmt, error) {
 }
 
 // Stmtx returns a version of the prepared statement which runs within a transaction.  Provided
-// stmt can be either *sql.Stmt or *sqlx.Stmt.
+// stmt can be either *sql.Stmt or *sqlx.Stmt.  The query and safety behavior of an
+// *sqlx.Stmt are kept;  an *sql.Stmt inherits the safety behavior of the transaction.
 func (tx *Tx) Stmtx(stmt interface{}) *Stmt {
     var s *sql.Stmt
+    var query string
+    unsafe, errorArgs := tx.unsafe, tx.errorArgs
     switch v := stmt.(type) {
     case Stmt:
-        s = v.Stmt
+        s, query, unsafe, errorArgs = v.Stmt, v.query, v.unsafe, v.errorArgs
     case *Stmt:
-        s = v.Stmt
+        s, query, unsafe, errorArgs = v.Stmt, v.query, v.unsafe, v.errorArgs
     case *sql.Stmt:
         s = v
     default:
         panic(fmt.Sprintf("non-statement type %v passed to Stmtx", reflect.ValueOf(stmt).Type()))
     }
-    return &Stmt{Stmt: tx.Stmt(s), Mapper: tx.Mapper}
+    return &Stmt{Stmt: tx.Stmt(s), query: query, unsafe: unsafe, errorArgs: errorArgs, Mapper: tx.Mapper}
 }
 
 // NamedStmt returns a version of the prepared statement which runs within a transaction.